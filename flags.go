@@ -13,11 +13,14 @@ import (
 	"github.com/NathanBaulch/rainbow-roads/conv"
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/locale"
 	"github.com/NathanBaulch/rainbow-roads/parse"
 	"github.com/araddon/dateparse"
 	"github.com/bcicen/go-units"
 	"github.com/paulmach/orb"
 	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/language"
 )
 
 func filterFlagSet(selector *parse.Selector) *pflag.FlagSet {
@@ -34,12 +37,38 @@ func filterFlagSet(selector *parse.Selector) *pflag.FlagSet {
 	fs.Var(&GeometryFlag{Geometry: &selector.BoundedBy}, "bounded_by", "region that activities must be fully contained within, eg circle(-37.8,144.9,10km)")
 	fs.Var(&GeometryFlag{Geometry: &selector.StartsNear}, "starts_near", "region that activities must start from, eg circle(51.53,-0.21,1km)")
 	fs.Var(&GeometryFlag{Geometry: &selector.EndsNear}, "ends_near", "region that activities must end in, eg circle(30.06,31.22,1km)")
-	fs.Var(&GeometryFlag{Geometry: &selector.PassesThrough}, "passes_through", "region that activities must pass through, eg circle(40.69,-74.12,10mi)")
+	fs.Var((*GeometriesFlag)(&selector.PassesThrough), "passes_through", "region that activities must pass through, can be specified multiple times, eg circle(40.69,-74.12,10mi)")
+	fs.Var((*WhereFlag)(&selector.Where), "where", "expression that activities must satisfy, using "+strings.Join(parse.WhereVariables, ", ")+", eg sport == 'running' and weekday in ['Sat','Sun'] and distance > 21000")
 	return fs
 }
 
+func cleanFlagSet(cleaner *parse.Cleaner) *pflag.FlagSet {
+	fs := &pflag.FlagSet{}
+	fs.Var((*SpeedFlag)(&cleaner.MaxSpeed), "max_speed", "fastest plausible speed, faster points are dropped as outliers, defaults by sport, eg 60km/h")
+	fs.BoolVar(&cleaner.KeepOutliers, "keep_outliers", false, "disable dropping of points that imply impossible speeds")
+	fs.StringVar(&cleaner.Smoother, "smooth", "none", "track smoothing algorithm, supports none, moving_average, kalman")
+	fs.UintVar(&cleaner.Window, "smooth_window", 5, "number of points averaged by the moving_average smoother")
+	fs.Var((*GeometriesFlag)(&cleaner.PrivacyZones), "privacy_zone", "region hidden from the start and end of activities, can be specified multiple times, eg circle(-37.8,144.9,200m)")
+	fs.Var((*DistanceFlag)(&cleaner.PrivacyTrim), "privacy_trim", "distance hidden from the start and end of every activity, eg 300m")
+	fs.StringVar(&cleaner.KeepDuplicate, "keep_duplicate", "longest", "copy kept when the same activity is recorded more than once, supports "+strings.Join(parse.KeepPolicies, ", "))
+	return fs
+}
+
+func validateCleaner(cleaner *parse.Cleaner) error {
+	if !slices.Contains(parse.Smoothers, cleaner.Smoother) {
+		return flagError("smooth", cleaner.Smoother, "not supported")
+	}
+	if cleaner.Window == 0 {
+		return flagError("smooth_window", cleaner.Window, "must be positive")
+	}
+	if !slices.Contains(parse.KeepPolicies, cleaner.KeepDuplicate) {
+		return flagError("keep_duplicate", cleaner.KeepDuplicate, "not supported")
+	}
+	return nil
+}
+
 func flagError(name string, value any, reason string) error {
-	return fmt.Errorf("invalid value %q for flag --%s: %s", value, name, reason)
+	return fmt.Errorf("invalid value %q for flag --%s: %s", fmt.Sprint(value), name, reason)
 }
 
 type ColorsFlag img.ColorGradient
@@ -59,6 +88,54 @@ func (c *ColorsFlag) String() string {
 	return (*img.ColorGradient)(c).String()
 }
 
+type PositionFlag img.Position
+
+func (p *PositionFlag) Type() string {
+	return "position"
+}
+
+func (p *PositionFlag) Set(str string) error {
+	return (*img.Position)(p).Parse(str)
+}
+
+func (p *PositionFlag) String() string {
+	if p == nil {
+		return ""
+	}
+	return img.Position(*p).String()
+}
+
+type RangeFlag [2]float64
+
+func (r *RangeFlag) Type() string {
+	return "range"
+}
+
+func (r *RangeFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	if parts := strings.Split(str, ","); len(parts) != 2 {
+		return errors.New("invalid number of parts")
+	} else if lo, err := strconv.ParseFloat(parts[0], 64); err != nil {
+		return fmt.Errorf("minimum %q not recognized", parts[0])
+	} else if hi, err := strconv.ParseFloat(parts[1], 64); err != nil {
+		return fmt.Errorf("maximum %q not recognized", parts[1])
+	} else if lo >= hi {
+		return errors.New("minimum must be less than maximum")
+	} else {
+		*r = RangeFlag{lo, hi}
+		return nil
+	}
+}
+
+func (r *RangeFlag) String() string {
+	if r == nil || *r == (RangeFlag{}) {
+		return ""
+	}
+	return conv.FormatFloat(r[0]) + "," + conv.FormatFloat(r[1])
+}
+
 type SportsFlag []string
 
 func (s *SportsFlag) Type() string {
@@ -80,6 +157,72 @@ func (s *SportsFlag) String() string {
 	return strings.Join(*s, ",")
 }
 
+type UnitsFlag locale.Units
+
+func (u *UnitsFlag) Type() string {
+	return "units"
+}
+
+func (u *UnitsFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	str = strings.ToLower(str)
+	if !slices.Contains(locale.UnitSystems, str) {
+		return fmt.Errorf("units %q not supported", str)
+	}
+	*u = UnitsFlag(str)
+	return nil
+}
+
+func (u *UnitsFlag) String() string {
+	return string(*u)
+}
+
+type LocaleFlag language.Tag
+
+func (l *LocaleFlag) Type() string {
+	return "locale"
+}
+
+func (l *LocaleFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	if tag, err := language.Parse(str); err != nil {
+		return fmt.Errorf("locale %q not recognized", str)
+	} else {
+		*l = LocaleFlag(tag)
+		return nil
+	}
+}
+
+func (l *LocaleFlag) String() string {
+	return language.Tag(*l).String()
+}
+
+type WhereFlag parse.Where
+
+func (w *WhereFlag) Type() string {
+	return "expression"
+}
+
+func (w *WhereFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	if where, err := parse.CompileWhere(str); err != nil {
+		return err
+	} else {
+		*w = WhereFlag(where)
+		return nil
+	}
+}
+
+func (w *WhereFlag) String() string {
+	return (*parse.Where)(w).String()
+}
+
 type DateFlag time.Time
 
 func (d *DateFlag) Type() string {
@@ -161,6 +304,39 @@ func (d *DistanceFlag) String() string {
 	return conv.FormatFloat(float64(*d))
 }
 
+type SpeedFlag float64
+
+func (s *SpeedFlag) Type() string {
+	return "speed"
+}
+
+var speedRE = regexp.MustCompile(`^([^/]+)/([^/]+)$`)
+
+func (s *SpeedFlag) Set(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty value")
+	}
+	if m := speedRE.FindStringSubmatch(str); len(m) != 3 {
+		return errors.New("format not recognized")
+	} else if dist, err := parseDistance(m[1]); err != nil {
+		return err
+	} else if dist == 0 {
+		return errors.New("must be positive")
+	} else if d, err := time.ParseDuration("1" + m[2]); err != nil {
+		return fmt.Errorf("duration unit %q not recognized", m[2])
+	} else {
+		*s = SpeedFlag(dist / d.Seconds())
+		return nil
+	}
+}
+
+func (s *SpeedFlag) String() string {
+	if s == nil || *s == 0 {
+		return ""
+	}
+	return conv.FormatFloat(float64(*s)) + "m/s"
+}
+
 type PaceFlag time.Duration
 
 func (p *PaceFlag) Type() string {
@@ -217,6 +393,14 @@ func (g *GeometryFlag) Set(str string) error {
 			return g.setSquare(match[2])
 		case "circle":
 			return g.setCircle(match[2])
+		case "bbox":
+			return g.setBBox(match[2])
+		case "corridor":
+			return g.setCorridor(match[2])
+		case "file":
+			return g.setFile(match[2])
+		case "union", "intersection", "difference":
+			return g.setCombination(match[1], match[2])
 		default:
 			return fmt.Errorf("geometry %q not recognized", match[1])
 		}
@@ -228,14 +412,8 @@ func (g *GeometryFlag) Set(str string) error {
 func (g *GeometryFlag) setCircle(str string) error {
 	if parts := strings.Split(str, ","); len(parts) < 2 || len(parts) > 3 {
 		return errors.New("invalid number of parts")
-	} else if lat, err := strconv.ParseFloat(parts[0], 64); err != nil {
-		return fmt.Errorf("latitude %q not recognized", parts[0])
-	} else if lon, err := strconv.ParseFloat(parts[1], 64); err != nil {
-		return fmt.Errorf("longitude %q not recognized", parts[1])
-	} else if lat < -85 || lat > 85 {
-		return fmt.Errorf("latitude %q not within range", conv.FormatFloat(lat))
-	} else if lon < -180 || lon > 180 {
-		return fmt.Errorf("longitude %q not within range", conv.FormatFloat(lon))
+	} else if origin, err := parsePoint(parts[0], parts[1]); err != nil {
+		return err
 	} else {
 		radius := 1000.0
 		if len(parts) == 3 {
@@ -243,7 +421,7 @@ func (g *GeometryFlag) setCircle(str string) error {
 				return errors.New("radius " + err.Error())
 			}
 		}
-		*g.Geometry = geo.Circle{Origin: orb.Point{lon, lat}, Radius: radius}
+		*g.Geometry = geo.Circle{Origin: origin, Radius: radius}
 		return nil
 	}
 }
@@ -251,14 +429,8 @@ func (g *GeometryFlag) setCircle(str string) error {
 func (g *GeometryFlag) setSquare(str string) error {
 	if parts := strings.Split(str, ","); len(parts) < 2 || len(parts) > 4 {
 		return errors.New("invalid number of parts")
-	} else if lat, err := strconv.ParseFloat(parts[0], 64); err != nil {
-		return fmt.Errorf("latitude %q not recognized", parts[0])
-	} else if lon, err := strconv.ParseFloat(parts[1], 64); err != nil {
-		return fmt.Errorf("longitude %q not recognized", parts[1])
-	} else if lat < -85 || lat > 85 {
-		return fmt.Errorf("latitude %q not within range", conv.FormatFloat(lat))
-	} else if lon < -180 || lon > 180 {
-		return fmt.Errorf("longitude %q not within range", conv.FormatFloat(lon))
+	} else if origin, err := parsePoint(parts[0], parts[1]); err != nil {
+		return err
 	} else {
 		size := 1000.0
 		if len(parts) >= 3 {
@@ -272,11 +444,116 @@ func (g *GeometryFlag) setSquare(str string) error {
 				return errors.New("angle " + err.Error())
 			}
 		}
-		*g.Geometry = geo.NewSquare(orb.Point{lon, lat}, size, angle)
+		*g.Geometry = geo.NewSquare(origin, size, angle)
+		return nil
+	}
+}
+
+func (g *GeometryFlag) setBBox(str string) error {
+	if parts := strings.Split(str, ","); len(parts) != 4 {
+		return errors.New("invalid number of parts")
+	} else if sw, err := parsePoint(parts[0], parts[1]); err != nil {
+		return err
+	} else if ne, err := parsePoint(parts[2], parts[3]); err != nil {
+		return err
+	} else if sw.Lat() >= ne.Lat() {
+		return errors.New("south not less than north")
+	} else if sw.Lon() >= ne.Lon() {
+		return errors.New("west not less than east")
+	} else {
+		*g.Geometry = geo.BBox{Box: orb.Bound{Min: sw, Max: ne}}
+		return nil
+	}
+}
+
+func (g *GeometryFlag) setCorridor(str string) error {
+	parts := strings.Split(str, ",")
+	if len(parts) < 4 {
+		return errors.New("invalid number of parts")
+	}
+	buffer := 50.0
+	if len(parts)%2 == 1 {
+		var err error
+		if buffer, err = parseDistance(parts[len(parts)-1]); err != nil {
+			return errors.New("buffer " + err.Error())
+		}
+		parts = parts[:len(parts)-1]
+	}
+	path := make(orb.LineString, len(parts)/2)
+	for i := range path {
+		var err error
+		if path[i], err = parsePoint(parts[2*i], parts[2*i+1]); err != nil {
+			return err
+		}
+	}
+	*g.Geometry = geo.Corridor{Path: path, Buffer: buffer}
+	return nil
+}
+
+func (g *GeometryFlag) setFile(str string) error {
+	if str == "" {
+		return errors.New("unexpected empty path")
+	} else if geom, err := geo.ReadFile(str); err != nil {
+		return fmt.Errorf("file %q: %w", str, err)
+	} else {
+		*g.Geometry = geom
 		return nil
 	}
 }
 
+func (g *GeometryFlag) setCombination(name, str string) error {
+	args, err := splitArgs(str)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("invalid number of parts")
+	}
+	geoms := make([]geo.Geometry, len(args))
+	for i, arg := range args {
+		if !geometryRE.MatchString(arg) {
+			return fmt.Errorf("%s part %q not a geometry", name, arg)
+		}
+		if err := (&GeometryFlag{Geometry: &geoms[i]}).Set(arg); err != nil {
+			return err
+		}
+	}
+	switch name {
+	case "union":
+		*g.Geometry = geo.Union{Geometries: geoms}
+	case "intersection":
+		*g.Geometry = geo.Intersection{Geometries: geoms}
+	default:
+		*g.Geometry = geo.Difference{Geometries: geoms}
+	}
+	return nil
+}
+
+// splitArgs splits the string on commas that are not nested within parentheses.
+func splitArgs(str string) ([]string, error) {
+	var args []string
+	depth, start := 0, 0
+	for i, c := range str {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return nil, errors.New("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(str[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced parentheses")
+	}
+	return append(args, strings.TrimSpace(str[start:])), nil
+}
+
 func (g *GeometryFlag) String() string {
 	if g == nil {
 		return ""
@@ -290,6 +567,46 @@ func (g *GeometryFlag) String() string {
 	return (*g.Geometry).String()
 }
 
+func parsePoint(latStr, lonStr string) (orb.Point, error) {
+	if lat, err := strconv.ParseFloat(latStr, 64); err != nil {
+		return orb.Point{}, fmt.Errorf("latitude %q not recognized", latStr)
+	} else if lon, err := strconv.ParseFloat(lonStr, 64); err != nil {
+		return orb.Point{}, fmt.Errorf("longitude %q not recognized", lonStr)
+	} else if lat < -85 || lat > 85 {
+		return orb.Point{}, fmt.Errorf("latitude %q not within range", conv.FormatFloat(lat))
+	} else if lon < -180 || lon > 180 {
+		return orb.Point{}, fmt.Errorf("longitude %q not within range", conv.FormatFloat(lon))
+	} else {
+		return orb.Point{lon, lat}, nil
+	}
+}
+
+type GeometriesFlag []geo.Geometry
+
+func (g *GeometriesFlag) Type() string {
+	return "geometry"
+}
+
+func (g *GeometriesFlag) Set(str string) error {
+	var geom geo.Geometry
+	if err := (&GeometryFlag{Geometry: &geom}).Set(str); err != nil {
+		return err
+	}
+	*g = append(*g, geom)
+	return nil
+}
+
+func (g *GeometriesFlag) String() string {
+	if g == nil {
+		return ""
+	}
+	strs := make([]string, len(*g))
+	for i, geom := range *g {
+		strs[i] = geom.String()
+	}
+	return strings.Join(strs, " ")
+}
+
 var distanceRE = regexp.MustCompile(`^(.*\d)\s?(\w+)?$`)
 
 func parseDistance(str string) (float64, error) {
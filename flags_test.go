@@ -3,6 +3,8 @@ package main
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
@@ -41,6 +43,95 @@ func TestSportsSet(t *testing.T) {
 	}
 }
 
+func TestUnitsSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"metric", "metric"},
+		{"Imperial", "imperial"},
+		{"", errors.New("unexpected empty value")},
+		{"nautical", errors.New(`units "nautical" not supported`)},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			var u UnitsFlag
+			if err := u.Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					is.NoError(err)
+				} else {
+					is.EqualError(err, expectErr.Error())
+				}
+			} else {
+				is.Equal(testCase.expect, u.String())
+			}
+		})
+	}
+}
+
+func TestLocaleSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"de", "de"},
+		{"en-us", "en-US"},
+		{"", errors.New("unexpected empty value")},
+		{"not a locale", errors.New(`locale "not a locale" not recognized`)},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			var l LocaleFlag
+			if err := l.Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					is.NoError(err)
+				} else {
+					is.EqualError(err, expectErr.Error())
+				}
+			} else {
+				is.Equal(testCase.expect, l.String())
+			}
+		})
+	}
+}
+
+func TestWhereSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"sport == 'running'", "sport == 'running'"},
+		{"distance > 21000 and weekday in ['Sat','Sun']", "distance > 21000 and weekday in ['Sat','Sun']"},
+		{"len(name) > 0 and lower(sport) == 'running'", "len(name) > 0 and lower(sport) == 'running'"},
+		{"", errors.New("unexpected empty value")},
+		{"foo == 1", errors.New("unknown name foo (1:1)\n | foo == 1\n | ^")},
+		{"sport", errors.New("expected bool, but got string")},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			var w WhereFlag
+			if err := w.Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					is.NoError(err)
+				} else {
+					is.EqualError(err, expectErr.Error())
+				}
+			} else {
+				is.Equal(testCase.expect, w.String())
+			}
+		})
+	}
+}
+
 func TestTimeSet(t *testing.T) {
 	testCases := []struct {
 		set    string
@@ -141,6 +232,39 @@ func TestDistanceSet(t *testing.T) {
 	}
 }
 
+func TestSpeedSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"36km/h", "10m/s"},
+		{"10m/s", "10m/s"},
+		{"3000ft/m", "15.24m/s"},
+		{"", errors.New("unexpected empty value")},
+		{"36km", errors.New("format not recognized")},
+		{"0km/h", errors.New("must be positive")},
+		{"36x/h", errors.New(`unit "x" not recognized`)},
+		{"36km/x", errors.New(`duration unit "x" not recognized`)},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			var f SpeedFlag
+			if err := f.Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					is.NoError(err)
+				} else {
+					is.EqualError(err, expectErr.Error())
+				}
+			} else {
+				is.Equal(testCase.expect, f.String())
+			}
+		})
+	}
+}
+
 func TestPaceFlag(t *testing.T) {
 	testCases := []struct {
 		set    string
@@ -178,6 +302,9 @@ func TestPaceFlag(t *testing.T) {
 }
 
 func TestRegionSet(t *testing.T) {
+	polyFile := filepath.Join(t.TempDir(), "region.geojson")
+	require.NoError(t, os.WriteFile(polyFile, []byte(`{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}`), 0o666))
+
 	testCases := []struct {
 		set    string
 		expect any
@@ -205,6 +332,27 @@ func TestRegionSet(t *testing.T) {
 		{"100,0", errors.New(`latitude "100" not within range`)},
 		{"0,200", errors.New(`longitude "200" not within range`)},
 		{"1,2,-3", errors.New(`radius must be positive`)},
+		{"bbox(1,2,3,4)", "bbox(1,2,3,4)"},
+		{"bbox(-37.9,144.8,-37.7,145)", "bbox(-37.9,144.8,-37.7,145)"},
+		{"bbox(1,2,3)", errors.New("invalid number of parts")},
+		{"bbox(3,2,1,4)", errors.New("south not less than north")},
+		{"bbox(1,4,3,2)", errors.New("west not less than east")},
+		{"bbox(1,2,95,4)", errors.New(`latitude "95" not within range`)},
+		{"corridor(1,2,3,4)", "corridor(1,2,3,4,50)"},
+		{"corridor(1,2,3,4,5,6,100ft)", "corridor(1,2,3,4,5,6,30.48)"},
+		{"corridor(1,2,3)", errors.New("invalid number of parts")},
+		{"corridor(1,2,3,foo,5)", errors.New(`longitude "foo" not recognized`)},
+		{"corridor(1,2,3,4,5x)", errors.New(`buffer unit "x" not recognized`)},
+		{"union(circle(1,2),square(3,4,5))", "union(circle(1,2,1000),square(3,4,5,0))"},
+		{"intersection(circle(1,2), bbox(0,1,2,3), corridor(1,2,3,4))", "intersection(circle(1,2,1000),bbox(0,1,2,3),corridor(1,2,3,4,50))"},
+		{"difference(union(circle(1,2),circle(3,4)),square(1,2,3))", "difference(union(circle(1,2,1000),circle(3,4,1000)),square(1,2,3,0))"},
+		{"union(circle(1,2))", errors.New("invalid number of parts")},
+		{"union(1,2,3)", errors.New(`union part "1" not a geometry`)},
+		{"union(circle(1,2),circle(3,4)", errors.New("unbalanced parentheses")},
+		{"intersection(circle(1,2),foo(3,4))", errors.New(`geometry "foo" not recognized`)},
+		{"file()", errors.New(`unexpected empty path`)},
+		{"file(missing.kml)", errors.New(`file "missing.kml": open missing.kml: no such file or directory`)},
+		{"file(" + polyFile + ")", "file(" + polyFile + ")"},
 	}
 
 	for i, testCase := range testCases {
@@ -226,3 +374,67 @@ func TestRegionSet(t *testing.T) {
 		})
 	}
 }
+
+func TestGeometriesSet(t *testing.T) {
+	testCases := []struct {
+		sets   []string
+		expect any
+	}{
+		{[]string{"1,2"}, "circle(1,2,1000)"},
+		{[]string{"1,2,3", "square(4,5,6)"}, "circle(1,2,3) square(4,5,6,0)"},
+		{[]string{"1,2", ""}, errors.New("unexpected empty value")},
+		{[]string{"foo(1,2)"}, errors.New(`geometry "foo" not recognized`)},
+		{[]string{"union(circle(1,2),bbox(3,4,5,6))", "difference(square(1,2),circle(1,2,3))"}, "union(circle(1,2,1000),bbox(3,4,5,6)) difference(square(1,2,1000,0),circle(1,2,3))"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			var f GeometriesFlag
+			for _, set := range testCase.sets {
+				if err := f.Set(set); err != nil {
+					if expectErr, ok := testCase.expect.(error); !ok {
+						is.NoError(err)
+					} else {
+						is.EqualError(err, expectErr.Error())
+						return
+					}
+				}
+			}
+			is.Equal(testCase.expect, f.String())
+		})
+	}
+}
+
+func TestRangeSet(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"1,2", "1,2"},
+		{"-10,10.5", "-10,10.5"},
+		{"", errors.New("unexpected empty value")},
+		{"1", errors.New("invalid number of parts")},
+		{"foo,2", errors.New(`minimum "foo" not recognized`)},
+		{"1,foo", errors.New(`maximum "foo" not recognized`)},
+		{"2,1", errors.New("minimum must be less than maximum")},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			var r RangeFlag
+			if err := r.Set(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					is.NoError(err)
+				} else {
+					is.EqualError(err, expectErr.Error())
+				}
+			} else {
+				is.Equal(testCase.expect, r.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/paulmach/orb"
+)
+
+// Union contains points within any of its geometries.
+type Union struct {
+	Geometries []Geometry
+}
+
+func (u Union) String() string {
+	return combineString("union", u.Geometries)
+}
+
+func (u Union) Contains(pt orb.Point) bool {
+	for _, g := range u.Geometries {
+		if g.Contains(pt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u Union) Bound() orb.Bound {
+	if len(u.Geometries) == 0 {
+		return orb.Bound{}
+	}
+	b := u.Geometries[0].Bound()
+	for _, g := range u.Geometries[1:] {
+		b = b.Union(g.Bound())
+	}
+	return b
+}
+
+// Ring returns the rectangle bounding all the geometries.
+func (u Union) Ring() orb.Ring {
+	return boundRing(u.Bound())
+}
+
+func (u Union) Grow(factor float64) Geometry {
+	u.Geometries = growAll(u.Geometries, factor)
+	return u
+}
+
+// Intersection contains points within all of its geometries.
+type Intersection struct {
+	Geometries []Geometry
+}
+
+func (i Intersection) String() string {
+	return combineString("intersection", i.Geometries)
+}
+
+func (i Intersection) Contains(pt orb.Point) bool {
+	for _, g := range i.Geometries {
+		if !g.Contains(pt) {
+			return false
+		}
+	}
+	return len(i.Geometries) > 0
+}
+
+func (i Intersection) Bound() orb.Bound {
+	if len(i.Geometries) == 0 {
+		return orb.Bound{}
+	}
+	b := i.Geometries[0].Bound()
+	for _, g := range i.Geometries[1:] {
+		gb := g.Bound()
+		b.Min = orb.Point{math.Max(b.Min[0], gb.Min[0]), math.Max(b.Min[1], gb.Min[1])}
+		b.Max = orb.Point{math.Min(b.Max[0], gb.Max[0]), math.Min(b.Max[1], gb.Max[1])}
+	}
+	if b.Min[0] > b.Max[0] || b.Min[1] > b.Max[1] {
+		return orb.Bound{Min: b.Min, Max: b.Min}
+	}
+	return b
+}
+
+// Ring returns the rectangle bounding the overlap of the geometries.
+func (i Intersection) Ring() orb.Ring {
+	return boundRing(i.Bound())
+}
+
+func (i Intersection) Grow(factor float64) Geometry {
+	i.Geometries = growAll(i.Geometries, factor)
+	return i
+}
+
+// Difference contains points within the first geometry but not within any of the others.
+type Difference struct {
+	Geometries []Geometry
+}
+
+func (d Difference) String() string {
+	return combineString("difference", d.Geometries)
+}
+
+func (d Difference) Contains(pt orb.Point) bool {
+	if len(d.Geometries) == 0 || !d.Geometries[0].Contains(pt) {
+		return false
+	}
+	for _, g := range d.Geometries[1:] {
+		if g.Contains(pt) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d Difference) Bound() orb.Bound {
+	if len(d.Geometries) == 0 {
+		return orb.Bound{}
+	}
+	return d.Geometries[0].Bound()
+}
+
+// Ring returns the outline of the first geometry, or its bounding rectangle if it has no outline.
+func (d Difference) Ring() orb.Ring {
+	if len(d.Geometries) == 0 {
+		return orb.Ring{}
+	}
+	if r := d.Geometries[0].Ring(); len(r) > 0 {
+		return r
+	}
+	return boundRing(d.Bound())
+}
+
+// Grow enlarges the first geometry while shrinking the ones subtracted from it.
+func (d Difference) Grow(factor float64) Geometry {
+	if len(d.Geometries) > 0 {
+		geoms := make([]Geometry, len(d.Geometries))
+		geoms[0] = d.Geometries[0].Grow(factor)
+		for i, g := range d.Geometries[1:] {
+			geoms[i+1] = g.Grow(1 / factor)
+		}
+		d.Geometries = geoms
+	}
+	return d
+}
+
+func combineString(name string, geoms []Geometry) string {
+	strs := make([]string, len(geoms))
+	for i, g := range geoms {
+		strs[i] = g.String()
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(strs, ","))
+}
+
+func growAll(geoms []Geometry, factor float64) []Geometry {
+	grown := make([]Geometry, len(geoms))
+	for i, g := range geoms {
+		grown[i] = g.Grow(factor)
+	}
+	return grown
+}
+
+func boundRing(b orb.Bound) orb.Ring {
+	return orb.Ring{
+		{b.Left(), b.Bottom()},
+		{b.Left(), b.Top()},
+		{b.Right(), b.Top()},
+		{b.Right(), b.Bottom()},
+	}
+}
@@ -0,0 +1,58 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	boxA = BBox{Box: orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{2, 2}}}
+	boxB = BBox{Box: orb.Bound{Min: orb.Point{1, 1}, Max: orb.Point{3, 3}}}
+)
+
+func TestUnion(t *testing.T) {
+	is := require.New(t)
+
+	u := Union{Geometries: []Geometry{boxA, boxB}}
+	is.Equal("union(bbox(0,0,2,2),bbox(1,1,3,3))", u.String())
+	is.True(u.Contains(orb.Point{0.5, 0.5}))
+	is.True(u.Contains(orb.Point{2.5, 2.5}))
+	is.False(u.Contains(orb.Point{0.5, 2.5}))
+	is.Equal(orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{3, 3}}, u.Bound())
+	is.Equal(orb.Ring{{0, 0}, {0, 3}, {3, 3}, {3, 0}}, u.Ring())
+	is.Equal("union(bbox(-1,-1,3,3),bbox(0,0,4,4))", u.Grow(2).String())
+}
+
+func TestIntersection(t *testing.T) {
+	is := require.New(t)
+
+	i := Intersection{Geometries: []Geometry{boxA, boxB}}
+	is.Equal("intersection(bbox(0,0,2,2),bbox(1,1,3,3))", i.String())
+	is.True(i.Contains(orb.Point{1.5, 1.5}))
+	is.False(i.Contains(orb.Point{0.5, 0.5}))
+	is.False(i.Contains(orb.Point{2.5, 2.5}))
+	is.Equal(orb.Bound{Min: orb.Point{1, 1}, Max: orb.Point{2, 2}}, i.Bound())
+	is.False(Intersection{}.Contains(orb.Point{}))
+
+	far := BBox{Box: orb.Bound{Min: orb.Point{5, 5}, Max: orb.Point{6, 6}}}
+	b := Intersection{Geometries: []Geometry{boxA, far}}.Bound()
+	is.Equal(b.Min, b.Max)
+}
+
+func TestDifference(t *testing.T) {
+	is := require.New(t)
+
+	d := Difference{Geometries: []Geometry{boxA, boxB}}
+	is.Equal("difference(bbox(0,0,2,2),bbox(1,1,3,3))", d.String())
+	is.True(d.Contains(orb.Point{0.5, 0.5}))
+	is.False(d.Contains(orb.Point{1.5, 1.5}))
+	is.False(d.Contains(orb.Point{2.5, 2.5}))
+	is.Equal(boxA.Box, d.Bound())
+	is.Equal(boxA.Ring(), d.Ring())
+	is.Equal("difference(bbox(-1,-1,3,3),bbox(1.5,1.5,2.5,2.5))", d.Grow(2).String())
+
+	c := Difference{Geometries: []Geometry{Circle{Origin: orb.Point{1, 2}, Radius: 3000}, boxA}}
+	is.Len(c.Ring(), 4)
+}
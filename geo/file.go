@@ -0,0 +1,191 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ReadFile loads the polygons described by a GeoJSON, KML or GPX file, where GPX tracks and routes are treated as
+// closed outlines.
+func ReadFile(name string) (Geometry, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var polys orb.MultiPolygon
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".geojson", ".json":
+		polys, err = parseGeoJSON(data)
+	case ".kml":
+		polys, err = parseKML(data)
+	case ".gpx":
+		polys, err = parseGPX(data)
+	default:
+		return nil, fmt.Errorf("file extension %q not supported", ext)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(polys) - 1; i >= 0; i-- {
+		if len(polys[i]) == 0 || len(polys[i][0]) < 3 {
+			polys = append(polys[:i], polys[i+1:]...)
+			continue
+		}
+		for j, r := range polys[i] {
+			if !r.Closed() {
+				polys[i][j] = append(r, r[0])
+			}
+		}
+	}
+	switch len(polys) {
+	case 0:
+		return nil, errors.New("no polygons found")
+	case 1:
+		return Polygon{Rings: polys[0], Source: name}, nil
+	default:
+		return MultiPolygon{Polygons: polys, Source: name}, nil
+	}
+}
+
+func parseGeoJSON(data []byte) (orb.MultiPolygon, error) {
+	var polys orb.MultiPolygon
+	var collect func(g orb.Geometry)
+	collect = func(g orb.Geometry) {
+		switch g := g.(type) {
+		case orb.Polygon:
+			polys = append(polys, g)
+		case orb.MultiPolygon:
+			polys = append(polys, g...)
+		case orb.Collection:
+			for _, c := range g {
+				collect(c)
+			}
+		}
+	}
+
+	var probe struct{ Type string }
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	switch probe.Type {
+	case "FeatureCollection":
+		fc, err := geojson.UnmarshalFeatureCollection(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fc.Features {
+			collect(f.Geometry)
+		}
+	case "Feature":
+		f, err := geojson.UnmarshalFeature(data)
+		if err != nil {
+			return nil, err
+		}
+		collect(f.Geometry)
+	default:
+		g, err := geojson.UnmarshalGeometry(data)
+		if err != nil {
+			return nil, err
+		}
+		collect(g.Geometry())
+	}
+	return polys, nil
+}
+
+type kmlPolygon struct {
+	Outer string   `xml:"outerBoundaryIs>LinearRing>coordinates"`
+	Inner []string `xml:"innerBoundaryIs>LinearRing>coordinates"`
+}
+
+func parseKML(data []byte) (orb.MultiPolygon, error) {
+	var polys orb.MultiPolygon
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return polys, nil
+			}
+			return nil, err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "Polygon" {
+			var kp kmlPolygon
+			if err := dec.DecodeElement(&kp, &se); err != nil {
+				return nil, err
+			}
+			outer, err := parseKMLCoordinates(kp.Outer)
+			if err != nil {
+				return nil, err
+			}
+			poly := orb.Polygon{outer}
+			for _, in := range kp.Inner {
+				inner, err := parseKMLCoordinates(in)
+				if err != nil {
+					return nil, err
+				}
+				poly = append(poly, inner)
+			}
+			polys = append(polys, poly)
+		}
+	}
+}
+
+func parseKMLCoordinates(str string) (orb.Ring, error) {
+	fields := strings.Fields(str)
+	ring := make(orb.Ring, 0, len(fields))
+	for _, f := range fields {
+		parts := strings.Split(f, ",")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("coordinate %q not recognized", f)
+		}
+		lon, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("longitude %q not recognized", parts[0])
+		}
+		lat, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("latitude %q not recognized", parts[1])
+		}
+		ring = append(ring, orb.Point{lon, lat})
+	}
+	return ring, nil
+}
+
+func parseGPX(data []byte) (orb.MultiPolygon, error) {
+	g, err := gpx.ParseBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var polys orb.MultiPolygon
+	add := func(pts []gpx.GPXPoint) {
+		ring := make(orb.Ring, len(pts))
+		for i, p := range pts {
+			ring[i] = orb.Point{p.Longitude, p.Latitude}
+		}
+		polys = append(polys, orb.Polygon{ring})
+	}
+	for _, t := range g.Tracks {
+		for _, s := range t.Segments {
+			add(s.Points)
+		}
+	}
+	for _, r := range g.Routes {
+		add(r.Points)
+	}
+	return polys, nil
+}
@@ -0,0 +1,91 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFile(t *testing.T) {
+	testCases := []struct {
+		name, data string
+		expect     Geometry
+	}{
+		{
+			"polygon.geojson",
+			`{"type":"Polygon","coordinates":[[[0,0],[4,0],[4,4],[0,4],[0,0]],[[1,1],[3,1],[3,3],[1,3],[1,1]]]}`,
+			Polygon{Rings: donut},
+		},
+		{
+			"feature.geojson",
+			`{"type":"Feature","geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1]]]},"properties":{}}`,
+			Polygon{Rings: orb.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}},
+		},
+		{
+			"collection.json",
+			`{"type":"FeatureCollection","features":[
+				{"type":"Feature","geometry":{"type":"Point","coordinates":[5,5]},"properties":{}},
+				{"type":"Feature","geometry":{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]],[[[2,2],[3,2],[3,3],[2,2]]]]},"properties":{}}
+			]}`,
+			MultiPolygon{Polygons: orb.MultiPolygon{
+				{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+				{{{2, 2}, {3, 2}, {3, 3}, {2, 2}}},
+			}},
+		},
+		{
+			"zone.kml",
+			`<?xml version="1.0" encoding="UTF-8"?>
+			<kml xmlns="http://www.opengis.net/kml/2.2"><Document><Placemark><Polygon>
+				<outerBoundaryIs><LinearRing><coordinates>0,0,0 4,0,0 4,4,0 0,4,0 0,0,0</coordinates></LinearRing></outerBoundaryIs>
+				<innerBoundaryIs><LinearRing><coordinates>1,1 3,1 3,3 1,3 1,1</coordinates></LinearRing></innerBoundaryIs>
+			</Polygon></Placemark></Document></kml>`,
+			Polygon{Rings: donut},
+		},
+		{
+			"track.gpx",
+			`<?xml version="1.0" encoding="UTF-8"?>
+			<gpx version="1.1" creator="test"><trk><trkseg>
+				<trkpt lat="0" lon="0"></trkpt><trkpt lat="0" lon="1"></trkpt><trkpt lat="1" lon="1"></trkpt>
+			</trkseg></trk></gpx>`,
+			Polygon{Rings: orb.Polygon{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			is := require.New(t)
+
+			name := filepath.Join(t.TempDir(), tc.name)
+			is.NoError(os.WriteFile(name, []byte(tc.data), 0o666))
+
+			g, err := ReadFile(name)
+			is.NoError(err)
+			switch e := tc.expect.(type) {
+			case Polygon:
+				e.Source = name
+				is.Equal(e, g)
+			case MultiPolygon:
+				e.Source = name
+				is.Equal(e, g)
+			}
+		})
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	is := require.New(t)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "points.geojson")
+	is.NoError(os.WriteFile(name, []byte(`{"type":"Point","coordinates":[1,2]}`), 0o666))
+	_, err := ReadFile(name)
+	is.EqualError(err, "no polygons found")
+
+	name = filepath.Join(dir, "region.shp")
+	is.NoError(os.WriteFile(name, nil, 0o666))
+	_, err = ReadFile(name)
+	is.EqualError(err, `file extension ".shp" not supported`)
+}
@@ -3,6 +3,7 @@ package geo
 import (
 	"fmt"
 	"math"
+	"strings"
 
 	"github.com/NathanBaulch/rainbow-roads/conv"
 	"github.com/paulmach/orb"
@@ -124,4 +125,154 @@ func (s Square) computeRing() Square {
 	return s
 }
 
+// BBox is an axis-aligned box of latitudes and longitudes.
+type BBox struct {
+	Box orb.Bound
+}
+
+func (b BBox) String() string {
+	return fmt.Sprintf("bbox(%s,%s,%s,%s)", conv.FormatFloat(b.Box.Bottom()), conv.FormatFloat(b.Box.Left()), conv.FormatFloat(b.Box.Top()), conv.FormatFloat(b.Box.Right()))
+}
+
+func (b BBox) Contains(pt orb.Point) bool {
+	return b.Box.Contains(pt)
+}
+
+func (b BBox) Bound() orb.Bound {
+	return b.Box
+}
+
+func (b BBox) Ring() orb.Ring {
+	return boundRing(b.Box)
+}
+
+func (b BBox) Grow(factor float64) Geometry {
+	center := b.Box.Center()
+	halfWidth := (b.Box.Right() - b.Box.Left()) * factor / 2
+	halfHeight := (b.Box.Top() - b.Box.Bottom()) * factor / 2
+	b.Box = orb.Bound{
+		Min: orb.Point{center[0] - halfWidth, center[1] - halfHeight},
+		Max: orb.Point{center[0] + halfWidth, center[1] + halfHeight},
+	}
+	return b
+}
+
+// Corridor is the area within a buffer distance of a path, such as a trail or river bank.
+type Corridor struct {
+	Path   orb.LineString
+	Buffer float64
+}
+
+func (c Corridor) String() string {
+	parts := make([]string, 0, len(c.Path)*2+1)
+	for _, pt := range c.Path {
+		parts = append(parts, conv.FormatFloat(pt.Lat()), conv.FormatFloat(pt.Lon()))
+	}
+	parts = append(parts, conv.FormatFloat(c.Buffer))
+	return fmt.Sprintf("corridor(%s)", strings.Join(parts, ","))
+}
+
+func (c Corridor) Contains(pt orb.Point) bool {
+	if len(c.Path) == 1 {
+		return DistanceHaversine(c.Path[0], pt) < c.Buffer
+	}
+	proj := c.projector(pt)
+	for i := 1; i < len(c.Path); i++ {
+		if planar.DistanceFromSegmentSquared(proj(c.Path[i-1]), proj(c.Path[i]), orb.Point{}) < c.Buffer*c.Buffer {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Corridor) Bound() orb.Bound {
+	b := c.Path.Bound()
+	padLat := RadiansToDegrees(c.Buffer / earthRadiusMean)
+	maxLat := math.Min(math.Max(math.Abs(b.Top()), math.Abs(b.Bottom()))+padLat, 89)
+	padLon := padLat / math.Cos(DegreesToRadians(maxLat))
+	return orb.Bound{
+		Min: orb.Point{b.Left() - padLon, b.Bottom() - padLat},
+		Max: orb.Point{b.Right() + padLon, b.Top() + padLat},
+	}
+}
+
+// Ring approximates the outline of the corridor by walking out along one side of the path and back along the other,
+// with rounded ends and outside corners.
+func (c Corridor) Ring() orb.Ring {
+	if len(c.Path) == 0 {
+		return orb.Ring{}
+	}
+	origin := c.Path.Bound().Center()
+	proj := c.projector(origin)
+	unproj := func(pt orb.Point) orb.Point {
+		cosLat := math.Cos(DegreesToRadians(origin.Lat()))
+		return orb.Point{
+			origin.Lon() + RadiansToDegrees(pt[0]/(earthRadiusMean*cosLat)),
+			origin.Lat() + RadiansToDegrees(pt[1]/earthRadiusMean),
+		}
+	}
+
+	path := make([]orb.Point, 0, len(c.Path)*2)
+	for i, pt := range c.Path {
+		if pt = proj(pt); i == 0 || pt != path[len(path)-1] {
+			path = append(path, pt)
+		}
+	}
+	for i := len(path) - 2; i >= 0; i-- {
+		path = append(path, path[i])
+	}
+
+	const arcStep = math.Pi / 8
+	var ring orb.Ring
+	arc := func(center orb.Point, from, to float64) {
+		for to < from {
+			to += 2 * math.Pi
+		}
+		for a := from; a < to; a += arcStep {
+			ring = append(ring, unproj(orb.Point{center[0] + c.Buffer*math.Cos(a), center[1] + c.Buffer*math.Sin(a)}))
+		}
+		ring = append(ring, unproj(orb.Point{center[0] + c.Buffer*math.Cos(to), center[1] + c.Buffer*math.Sin(to)}))
+	}
+	if len(path) == 1 {
+		arc(path[0], 0, 2*math.Pi)
+		return ring
+	}
+
+	heading := func(i int) float64 { return math.Atan2(path[i+1][1]-path[i][1], path[i+1][0]-path[i][0]) }
+	prev := heading(0)
+	arc(path[0], prev+math.Pi/2, prev+3*math.Pi/2)
+	for i := 1; i < len(path)-1; i++ {
+		next := heading(i)
+		if turn := math.Remainder(next-prev, 2*math.Pi); turn > 0 || math.Abs(turn) >= math.Pi-1e-9 {
+			arc(path[i], prev-math.Pi/2, next-math.Pi/2)
+		} else {
+			miter := c.Buffer / (1 + math.Cos(turn))
+			ring = append(ring, unproj(orb.Point{
+				path[i][0] + miter*(math.Sin(prev)+math.Sin(next)),
+				path[i][1] - miter*(math.Cos(prev)+math.Cos(next)),
+			}))
+		}
+		prev = next
+	}
+	last := path[len(path)-1]
+	ring = append(ring, unproj(orb.Point{last[0] + c.Buffer*math.Cos(prev-math.Pi/2), last[1] + c.Buffer*math.Sin(prev-math.Pi/2)}))
+	return ring
+}
+
+func (c Corridor) Grow(factor float64) Geometry {
+	c.Buffer *= factor
+	return c
+}
+
+// projector returns a function that maps points onto a local plane in meters centered on the origin.
+func (c Corridor) projector(origin orb.Point) func(pt orb.Point) orb.Point {
+	cosLat := math.Cos(DegreesToRadians(origin.Lat()))
+	return func(pt orb.Point) orb.Point {
+		return orb.Point{
+			DegreesToRadians(pt.Lon()-origin.Lon()) * cosLat * earthRadiusMean,
+			DegreesToRadians(pt.Lat()-origin.Lat()) * earthRadiusMean,
+		}
+	}
+}
+
 var BoundWidth = geo.BoundWidth
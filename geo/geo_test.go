@@ -1,6 +1,7 @@
 package geo
 
 import (
+	"math"
 	"testing"
 
 	"github.com/paulmach/orb"
@@ -89,3 +90,91 @@ func TestSquareGrow(t *testing.T) {
 	s := NewSquare(orb.Point{1, 2}, 3000, 4)
 	is.Equal("square(2,1,3300,4)", s.Grow(1.1).String())
 }
+
+func TestBBoxString(t *testing.T) {
+	is := require.New(t)
+
+	b := BBox{Box: orb.Bound{Min: orb.Point{1, 2}, Max: orb.Point{3, 4}}}
+	is.Equal("bbox(2,1,4,3)", b.String())
+}
+
+func TestBBoxContains(t *testing.T) {
+	is := require.New(t)
+
+	b := BBox{Box: orb.Bound{Min: orb.Point{1, 2}, Max: orb.Point{3, 4}}}
+	is.True(b.Contains(orb.Point{2, 3}))
+	is.False(b.Contains(orb.Point{3.5, 3}))
+	is.False(b.Contains(orb.Point{2, 1.5}))
+}
+
+func TestBBoxRing(t *testing.T) {
+	is := require.New(t)
+
+	b := BBox{Box: orb.Bound{Min: orb.Point{1, 2}, Max: orb.Point{3, 4}}}
+	is.Equal(orb.Ring{{1, 2}, {1, 4}, {3, 4}, {3, 2}}, b.Ring())
+}
+
+func TestBBoxGrow(t *testing.T) {
+	is := require.New(t)
+
+	b := BBox{Box: orb.Bound{Min: orb.Point{1, 2}, Max: orb.Point{3, 4}}}
+	is.Equal("bbox(1.5,0.5,4.5,3.5)", b.Grow(1.5).String())
+}
+
+func TestCorridorString(t *testing.T) {
+	is := require.New(t)
+
+	c := Corridor{Path: orb.LineString{{1, 2}, {3, 4}}, Buffer: 50}
+	is.Equal("corridor(2,1,4,3,50)", c.String())
+}
+
+func TestCorridorContains(t *testing.T) {
+	is := require.New(t)
+
+	c := Corridor{Path: orb.LineString{{144.9, -37.8}, {144.91, -37.8}, {144.91, -37.79}}, Buffer: 50}
+	is.True(c.Contains(orb.Point{144.905, -37.8004}))
+	is.False(c.Contains(orb.Point{144.905, -37.8006}))
+	is.True(c.Contains(orb.Point{144.9105, -37.795}))
+	is.False(c.Contains(orb.Point{144.9, -37.79}))
+	is.False(c.Contains(orb.Point{144.8993, -37.8}))
+}
+
+func TestCorridorBound(t *testing.T) {
+	is := require.New(t)
+
+	c := Corridor{Path: orb.LineString{{144.9, -37.8}, {144.91, -37.79}}, Buffer: 1000}
+	b := c.Bound()
+	is.True(b.Contains(orb.Point{144.9, -37.8089}))
+	is.True(b.Contains(orb.Point{144.889, -37.8}))
+	is.False(b.Contains(orb.Point{144.9, -37.81}))
+	is.False(b.Contains(orb.Point{144.887, -37.8}))
+}
+
+func TestCorridorRing(t *testing.T) {
+	is := require.New(t)
+
+	c := Corridor{Path: orb.LineString{{144.9, -37.8}, {144.91, -37.8}, {144.91, -37.79}, {144.92, -37.79}}, Buffer: 50}
+	ring := c.Ring()
+	is.True(ring.Closed())
+	for _, pt := range ring {
+		is.InDelta(50, distanceToPath(c.Path, pt), 1)
+	}
+}
+
+func TestCorridorGrow(t *testing.T) {
+	is := require.New(t)
+
+	c := Corridor{Path: orb.LineString{{1, 2}, {3, 4}}, Buffer: 50}
+	is.Equal("corridor(2,1,4,3,75)", c.Grow(1.5).String())
+}
+
+func distanceToPath(path orb.LineString, pt orb.Point) float64 {
+	dist := math.Inf(1)
+	for i := 1; i < len(path); i++ {
+		for f := 0.0; f <= 1; f += 0.001 {
+			p := orb.Point{path[i-1][0] + (path[i][0]-path[i-1][0])*f, path[i-1][1] + (path[i][1]-path[i-1][1])*f}
+			dist = math.Min(dist, DistanceHaversine(p, pt))
+		}
+	}
+	return dist
+}
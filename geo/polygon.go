@@ -0,0 +1,101 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// Polygon is an outer ring with optional holes, typically loaded from a boundary file.
+type Polygon struct {
+	Rings  orb.Polygon
+	Source string
+}
+
+func (p Polygon) String() string {
+	return sourceString("polygon", p.Source)
+}
+
+func (p Polygon) Contains(pt orb.Point) bool {
+	return planar.PolygonContains(p.Rings, pt)
+}
+
+func (p Polygon) Bound() orb.Bound {
+	return p.Rings.Bound()
+}
+
+func (p Polygon) Ring() orb.Ring {
+	if len(p.Rings) == 0 {
+		return orb.Ring{}
+	}
+	return p.Rings[0]
+}
+
+func (p Polygon) Grow(factor float64) Geometry {
+	p.Rings = growPolygon(p.Rings, p.Bound().Center(), factor)
+	return p
+}
+
+type MultiPolygon struct {
+	Polygons orb.MultiPolygon
+	Source   string
+}
+
+func (m MultiPolygon) String() string {
+	return sourceString("multipolygon", m.Source)
+}
+
+func (m MultiPolygon) Contains(pt orb.Point) bool {
+	return planar.MultiPolygonContains(m.Polygons, pt)
+}
+
+func (m MultiPolygon) Bound() orb.Bound {
+	return m.Polygons.Bound()
+}
+
+// Ring returns the outer ring of the largest polygon.
+func (m MultiPolygon) Ring() orb.Ring {
+	ring, area := orb.Ring{}, 0.0
+	for _, p := range m.Polygons {
+		if len(p) > 0 {
+			if a := math.Abs(planar.Area(p[0])); a > area || len(ring) == 0 {
+				ring, area = p[0], a
+			}
+		}
+	}
+	return ring
+}
+
+func (m MultiPolygon) Grow(factor float64) Geometry {
+	center := m.Bound().Center()
+	polys := make(orb.MultiPolygon, len(m.Polygons))
+	for i, p := range m.Polygons {
+		polys[i] = growPolygon(p, center, factor)
+	}
+	m.Polygons = polys
+	return m
+}
+
+// growPolygon scales every ring of the polygon away from the center point.
+func growPolygon(p orb.Polygon, center orb.Point, factor float64) orb.Polygon {
+	grown := make(orb.Polygon, len(p))
+	for i, r := range p {
+		grown[i] = make(orb.Ring, len(r))
+		for j, pt := range r {
+			grown[i][j] = orb.Point{
+				center[0] + (pt[0]-center[0])*factor,
+				center[1] + (pt[1]-center[1])*factor,
+			}
+		}
+	}
+	return grown
+}
+
+func sourceString(kind, source string) string {
+	if source != "" {
+		return fmt.Sprintf("file(%s)", source)
+	}
+	return kind
+}
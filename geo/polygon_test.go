@@ -0,0 +1,63 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+var donut = orb.Polygon{
+	{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}},
+	{{1, 1}, {3, 1}, {3, 3}, {1, 3}, {1, 1}},
+}
+
+func TestPolygonString(t *testing.T) {
+	is := require.New(t)
+
+	is.Equal("polygon", Polygon{Rings: donut}.String())
+	is.Equal("file(zone.kml)", Polygon{Rings: donut, Source: "zone.kml"}.String())
+}
+
+func TestPolygonContains(t *testing.T) {
+	is := require.New(t)
+
+	p := Polygon{Rings: donut}
+	is.True(p.Contains(orb.Point{0.5, 0.5}))
+	is.False(p.Contains(orb.Point{2, 2}))
+	is.False(p.Contains(orb.Point{5, 5}))
+}
+
+func TestPolygonRing(t *testing.T) {
+	is := require.New(t)
+
+	is.Equal(donut[0], Polygon{Rings: donut}.Ring())
+	is.Empty(Polygon{}.Ring())
+}
+
+func TestPolygonGrow(t *testing.T) {
+	is := require.New(t)
+
+	p := Polygon{Rings: donut}.Grow(2)
+	is.Equal(orb.Bound{Min: orb.Point{-2, -2}, Max: orb.Point{6, 6}}, p.Bound())
+	is.Equal(orb.Ring{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}}, p.(Polygon).Rings[1])
+	is.Equal(orb.Point{0, 0}, donut[0][0])
+}
+
+func TestMultiPolygonContains(t *testing.T) {
+	is := require.New(t)
+
+	m := MultiPolygon{Polygons: orb.MultiPolygon{donut, {{{10, 10}, {11, 10}, {11, 11}, {10, 10}}}}}
+	is.True(m.Contains(orb.Point{0.5, 0.5}))
+	is.True(m.Contains(orb.Point{10.8, 10.2}))
+	is.False(m.Contains(orb.Point{2, 2}))
+	is.Equal(orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{11, 11}}, m.Bound())
+}
+
+func TestMultiPolygonRing(t *testing.T) {
+	is := require.New(t)
+
+	small := orb.Polygon{{{10, 10}, {11, 10}, {11, 11}, {10, 10}}}
+	m := MultiPolygon{Polygons: orb.MultiPolygon{small, donut}}
+	is.Equal(donut[0], m.Ring())
+}
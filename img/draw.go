@@ -1,27 +1,87 @@
 package img
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 
+	"golang.org/x/exp/slices"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/fixed"
 )
 
+type Position int
+
+const (
+	TopLeft Position = iota
+	TopRight
+	BottomLeft
+	BottomRight
+)
+
+var positionNames = []string{"top_left", "top_right", "bottom_left", "bottom_right"}
+
+func (p *Position) Parse(str string) error {
+	if i := slices.Index(positionNames, str); i < 0 {
+		return fmt.Errorf("position %q not recognized", str)
+	} else {
+		*p = Position(i)
+		return nil
+	}
+}
+
+func (p Position) String() string {
+	return positionNames[p]
+}
+
+func NewFace(size float64) (font.Face, error) {
+	f, err := opentype.Parse(gobold.TTF)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
 func DrawWatermark(im image.Image, text string, c color.Color) {
+	DrawText(im, basicfont.Face7x13, c, BottomRight, text)
+}
+
+// DrawText draws lines of text in a corner of the image, provided they fit.
+func DrawText(im image.Image, face font.Face, c color.Color, pos Position, lines ...string) {
+	const margin = 5
 	d := &font.Drawer{
 		Dst:  im.(draw.Image),
 		Src:  image.NewUniform(c),
-		Face: basicfont.Face7x13,
+		Face: face,
+	}
+	m := face.Metrics()
+	bounds := im.Bounds()
+	height := m.Height.Mul(fixed.I(len(lines)))
+	width := fixed.Int26_6(0)
+	for _, line := range lines {
+		width = max(width, d.MeasureString(line))
 	}
-	b, _ := d.BoundString(text)
-	b = b.Sub(b.Min)
-	if b.In(fixed.R(0, 0, im.Bounds().Max.X-10, im.Bounds().Max.Y-10)) {
-		d.Dot = fixed.P(im.Bounds().Max.X, im.Bounds().Max.Y).
-			Sub(b.Max.Sub(fixed.P(0, basicfont.Face7x13.Height))).
-			Sub(fixed.P(5, 5))
-		d.DrawString(text)
+	if width.Ceil() > bounds.Dx()-2*margin || height.Ceil() > bounds.Dy()-2*margin {
+		return
+	}
+
+	for i, line := range lines {
+		switch pos {
+		case TopLeft, BottomLeft:
+			d.Dot.X = fixed.I(bounds.Min.X + margin)
+		case TopRight, BottomRight:
+			d.Dot.X = fixed.I(bounds.Max.X-margin) - d.MeasureString(line)
+		}
+		switch pos {
+		case TopLeft, TopRight:
+			d.Dot.Y = fixed.I(bounds.Min.Y+margin) + m.Ascent + m.Height.Mul(fixed.I(i))
+		case BottomLeft, BottomRight:
+			d.Dot.Y = fixed.I(bounds.Max.Y-margin) - m.Descent - m.Height.Mul(fixed.I(len(lines)-1-i))
+		}
+		d.DrawString(line)
 	}
 }
@@ -0,0 +1,76 @@
+package img
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/font/basicfont"
+)
+
+func TestPositionParse(t *testing.T) {
+	testCases := []struct {
+		set    string
+		expect any
+	}{
+		{"top_left", TopLeft},
+		{"bottom_right", BottomRight},
+		{"middle", errors.New(`position "middle" not recognized`)},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			var p Position
+			if err := p.Parse(testCase.set); err != nil {
+				if expectErr, ok := testCase.expect.(error); !ok {
+					is.NoError(err)
+				} else {
+					is.EqualError(err, expectErr.Error())
+				}
+			} else {
+				is.Equal(testCase.expect, p)
+				is.Equal(testCase.set, p.String())
+			}
+		})
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	is := require.New(t)
+
+	face, err := NewFace(12)
+	is.NoError(err)
+
+	for _, pos := range []Position{TopLeft, TopRight, BottomLeft, BottomRight} {
+		im := image.NewGray(image.Rect(0, 0, 100, 100))
+		DrawText(im, face, color.White, pos, "foo", "bar")
+		var sx, sy, n int
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 100; x++ {
+				if im.GrayAt(x, y).Y > 0 {
+					sx += x
+					sy += y
+					n++
+				}
+			}
+		}
+		is.Positive(n)
+		is.Equal(pos == TopRight || pos == BottomRight, sx/n > 50, pos.String())
+		is.Equal(pos == BottomLeft || pos == BottomRight, sy/n > 50, pos.String())
+	}
+}
+
+func TestDrawTextTooLarge(t *testing.T) {
+	is := require.New(t)
+
+	im := image.NewGray(image.Rect(0, 0, 20, 20))
+	DrawText(im, basicfont.Face7x13, color.White, TopLeft, "foobar")
+	for _, p := range im.Pix {
+		is.Zero(p)
+	}
+}
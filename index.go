@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/NathanBaulch/rainbow-roads/index"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/text/language"
+)
+
+var (
+	indexOpts = &index.Options{
+		Locale: language.English,
+	}
+	indexCmd = &cobra.Command{
+		Use:   "index",
+		Short: "Build or update an index of activities",
+		PreRunE: func(*cobra.Command, []string) error {
+			if indexOpts.Jobs < 0 {
+				return flagError("jobs", indexOpts.Jobs, "must not be negative")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			indexOpts.Input = args
+			indexOpts.Cache = activityCache()
+			return withProgress(func(rep progress.Reporter) error {
+				indexOpts.Progress = rep
+				return index.Run(cmd.Context(), indexOpts)
+			})
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+
+	general := &pflag.FlagSet{}
+	general.StringVarP(&indexOpts.Output, "output", "o", "activities.idx", "path of the index to create or update")
+	general.Var((*LocaleFlag)(&indexOpts.Locale), "locale", "language used to print numbers and labels, eg de, en-US")
+	general.StringVar(&indexOpts.Report, "report", "", "optional path of a JSON report listing the outcome of every added or changed file")
+	general.IntVar(&indexOpts.Jobs, "jobs", 0, "number of files parsed concurrently, defaults to the number of CPUs")
+	general.BoolVar(&noCache, "no_cache", false, "disable the cache of parsed activities kept in the user cache directory")
+	general.VisitAll(indexCmd.Flags().AddFlag)
+
+	indexCmd.SetUsageFunc(func(*cobra.Command) error {
+		fmt.Fprintln(indexCmd.OutOrStderr())
+		fmt.Fprintln(indexCmd.OutOrStderr(), "Usage:")
+		fmt.Fprintln(indexCmd.OutOrStderr(), " ", indexCmd.UseLine(), "[input]")
+		fmt.Fprintln(indexCmd.OutOrStderr())
+		fmt.Fprintln(indexCmd.OutOrStderr(), "General flags:")
+		fmt.Fprint(indexCmd.OutOrStderr(), general.FlagUsages())
+		return nil
+	})
+}
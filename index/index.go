@@ -0,0 +1,334 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/output"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/paulmach/orb"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// formatVersion is bumped whenever the index layout changes, forcing existing indexes to be rebuilt.
+	formatVersion = 1
+	// simplifyTolerance is how far in meters simplified tracks may deviate from the recorded ones.
+	simplifyTolerance = 5
+)
+
+// Index is a catalogue of the activities found in a set of files, holding their metadata, bounds
+// and simplified tracks so they can be queried without rescanning or decoding the files.
+type Index struct {
+	Version int
+	Files   map[string]*File
+}
+
+// File is an indexed file, identified by its name, size and modification time.
+type File struct {
+	Size       int64
+	ModTime    time.Time
+	Outcome    string `msgpack:",omitempty"`
+	Activities []*Activity
+}
+
+// Activity is an indexed activity. DuplicateOf hints at the ID of the activity it duplicates.
+type Activity struct {
+	ID            string
+	Name          string `msgpack:",omitempty"`
+	Sport         string
+	Start, End    time.Time
+	Distance      float64
+	ElapsedTime   time.Duration
+	MovingTime    time.Duration
+	Duration      time.Duration
+	ElevationGain float64
+	Bound         orb.Bound
+	Points        int
+	Pauses        [][2]time.Time `msgpack:",omitempty"`
+	Track         []Point
+	DuplicateOf   string `msgpack:",omitempty"`
+}
+
+// Point is a simplified track point, with NaN sensor channels when missing.
+type Point struct {
+	Timestamp time.Time
+	Position  [2]float64
+	Elevation float64
+	HeartRate float64
+	Cadence   float64
+	Power     float64
+	Speed     float64
+}
+
+// Changes counts the files affected by an update along with the resulting activity totals.
+type Changes struct {
+	Added, Changed, Removed, Unchanged int
+	Activities, Duplicates             int
+	Diagnostics                        parse.Diagnostics
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{Version: formatVersion, Files: make(map[string]*File)}
+}
+
+// ErrVersion is returned when opening an index written in a different format, which must be rebuilt.
+var ErrVersion = errors.New("index format not supported, rebuild it with the index command")
+
+// Open reads the index at the given path.
+func Open(name string) (*Index, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Index{}
+	if err := msgpack.Unmarshal(data, ix); err != nil {
+		return nil, fmt.Errorf("index %q: %w", name, err)
+	}
+	if ix.Version != formatVersion {
+		return nil, ErrVersion
+	}
+	if ix.Files == nil {
+		ix.Files = make(map[string]*File)
+	}
+	return ix, nil
+}
+
+// Save atomically writes the index to the given path.
+func (ix *Index) Save(name string) error {
+	return output.Create(name, ix.Write)
+}
+
+// Write encodes the index to the given writer.
+func (ix *Index) Write(w io.Writer) error {
+	return msgpack.NewEncoder(w).Encode(ix)
+}
+
+// Update brings the index in line with the given files, decoding only those that were added or changed
+// since the last update and dropping those that no longer exist.
+func (ix *Index) Update(ctx context.Context, files []*scan.File, opts *parse.Options) (*Changes, error) {
+	changes := &Changes{}
+	seen := make(map[string]bool, len(files))
+	var pending []*scan.File
+	for _, f := range files {
+		seen[f.Name] = true
+		if prev, ok := ix.Files[f.Name]; !ok {
+			changes.Added++
+			pending = append(pending, f)
+		} else if prev.Size != f.Size || !prev.ModTime.Equal(f.ModTime) {
+			changes.Changed++
+			pending = append(pending, f)
+		} else {
+			changes.Unchanged++
+		}
+	}
+
+	acts, diags, err := parse.Decode(ctx, pending, opts)
+	if err != nil {
+		return nil, err
+	}
+	changes.Diagnostics = diags
+
+	for name := range ix.Files {
+		if !seen[name] {
+			delete(ix.Files, name)
+			changes.Removed++
+		}
+	}
+	for _, f := range pending {
+		ix.Files[f.Name] = &File{Size: f.Size, ModTime: f.ModTime}
+	}
+	for _, d := range diags {
+		if d.Outcome != parse.Parsed {
+			ix.Files[d.File].Outcome = d.Outcome
+		}
+	}
+	for _, act := range acts {
+		if len(act.Records) == 0 {
+			continue
+		}
+		f := ix.Files[act.File]
+		f.Activities = append(f.Activities, newActivity(act))
+	}
+
+	changes.Activities, changes.Duplicates = ix.dedupe()
+	return changes, nil
+}
+
+func newActivity(act *parse.Activity) *Activity {
+	r0, r1 := act.Records[0], act.Records[len(act.Records)-1]
+	a := &Activity{
+		ID:            act.File + "@" + r0.Timestamp.UTC().Format(time.RFC3339),
+		Name:          act.Name,
+		Sport:         act.Sport,
+		Start:         r0.Timestamp,
+		End:           r1.Timestamp,
+		Distance:      act.Distance,
+		ElapsedTime:   act.ElapsedTime,
+		MovingTime:    act.MovingTime,
+		Duration:      act.Duration,
+		ElevationGain: act.ElevationGain,
+		Points:        len(act.Records),
+		Bound:         orb.Bound{Min: r0.Position, Max: r0.Position},
+	}
+	if a.ElevationGain == 0 {
+		a.ElevationGain = parse.ElevationGain(act.Records)
+	}
+	for _, p := range act.Pauses {
+		a.Pauses = append(a.Pauses, [2]time.Time{p.Start, p.End})
+	}
+	for _, r := range act.Records {
+		a.Bound = a.Bound.Extend(r.Position)
+	}
+	for _, i := range simplify(act.Records, simplifyTolerance) {
+		r := act.Records[i]
+		a.Track = append(a.Track, Point{
+			Timestamp: r.Timestamp,
+			Position:  r.Position,
+			Elevation: r.Elevation,
+			HeartRate: r.HeartRate,
+			Cadence:   r.Cadence,
+			Power:     r.Power,
+			Speed:     r.Speed,
+		})
+	}
+	return a
+}
+
+// dedupe marks activities recorded more than once, such as in both a Garmin and a Strava export, with the ID of
+// the copy kept by the default policy, and returns the number of distinct activities and duplicates.
+// The marks are only hints since every copy is queried, leaving the choice to the policy given at query time.
+func (ix *Index) dedupe() (int, int) {
+	var acts []*parse.Activity
+	indexed := make(map[*parse.Activity]*Activity)
+	for _, name := range ix.names() {
+		for _, a := range ix.Files[name].Activities {
+			a.DuplicateOf = ""
+			act := a.activity(name)
+			acts = append(acts, act)
+			indexed[act] = a
+		}
+	}
+
+	dupes := parse.Duplicates(acts, "")
+	for dup, keep := range dupes {
+		indexed[dup].DuplicateOf = indexed[keep].ID
+	}
+	return len(acts) - len(dupes), len(dupes)
+}
+
+// Activities returns every activity in the index with the simplified track as records, including duplicates
+// so that the copy kept can be chosen when they're selected.
+func (ix *Index) Activities() []*parse.Activity {
+	var acts []*parse.Activity
+	for _, name := range ix.names() {
+		for _, a := range ix.Files[name].Activities {
+			acts = append(acts, a.activity(name))
+		}
+	}
+	return acts
+}
+
+func (ix *Index) names() []string {
+	names := make([]string, 0, len(ix.Files))
+	for name := range ix.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (a *Activity) activity(file string) *parse.Activity {
+	act := &parse.Activity{
+		File:          file,
+		Name:          a.Name,
+		Sport:         a.Sport,
+		Distance:      a.Distance,
+		ElevationGain: a.ElevationGain,
+		ElapsedTime:   a.ElapsedTime,
+		MovingTime:    a.MovingTime,
+		Duration:      a.Duration,
+		Pauses:        make([]parse.Pause, len(a.Pauses)),
+		Records:       make([]*parse.Record, len(a.Track)),
+	}
+	for i, p := range a.Pauses {
+		act.Pauses[i] = parse.Pause{Start: p[0], End: p[1]}
+	}
+	for i, p := range a.Track {
+		act.Records[i] = &parse.Record{
+			Timestamp: p.Timestamp,
+			Position:  p.Position,
+			Elevation: p.Elevation,
+			HeartRate: p.HeartRate,
+			Cadence:   p.Cadence,
+			Power:     p.Power,
+			Speed:     p.Speed,
+		}
+	}
+	return act
+}
+
+// simplify returns the indexes of the records retained by Douglas-Peucker simplification,
+// measured in meters using a local projection.
+func simplify(recs []*parse.Record, tolerance float64) []int {
+	if len(recs) <= 2 {
+		idx := make([]int, len(recs))
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx
+	}
+
+	origin := recs[0].Position
+	kx := geo.DistanceHaversine(origin, orb.Point{origin.Lon() + 1, origin.Lat()})
+	ky := geo.DistanceHaversine(origin, orb.Point{origin.Lon(), origin.Lat() + 1})
+	pts := make([]orb.Point, len(recs))
+	for i, r := range recs {
+		pts[i] = orb.Point{(r.Position.Lon() - origin.Lon()) * kx, (r.Position.Lat() - origin.Lat()) * ky}
+	}
+
+	keep := make([]bool, len(pts))
+	keep[0], keep[len(pts)-1] = true, true
+	stack := [][2]int{{0, len(pts) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		maxDist, maxIdx := 0.0, -1
+		for i := s[0] + 1; i < s[1]; i++ {
+			if d := distanceFromSegment(pts[s[0]], pts[s[1]], pts[i]); d > maxDist {
+				maxDist, maxIdx = d, i
+			}
+		}
+		if maxDist > tolerance {
+			keep[maxIdx] = true
+			stack = append(stack, [2]int{s[0], maxIdx}, [2]int{maxIdx, s[1]})
+		}
+	}
+
+	var idx []int
+	for i, k := range keep {
+		if k {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+func distanceFromSegment(a, b, p orb.Point) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = max(0, min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/l))
+	}
+	return math.Hypot(p[0]-a[0]-t*dx, p[1]-a[1]-t*dy)
+}
@@ -0,0 +1,138 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/stretchr/testify/require"
+)
+
+var ts0 = time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+
+func gpxFile(name string, start time.Time, points, step int) *scan.File {
+	sb := &strings.Builder{}
+	sb.WriteString(`<gpx><trk><type>running</type><trkseg>`)
+	for i := 0; i < points; i += step {
+		// zigzag on odd points so that simplification keeps them
+		fmt.Fprintf(sb, `<trkpt lat="%.5f" lon="%.5f"><ele>%d</ele><time>%s</time></trkpt>`, -37.8+0.00005*float64(i)+0.0001*float64(i%2), 144.896+0.0001*float64(i), i%7, start.Add(time.Duration(i)*3*time.Second).Format(time.RFC3339))
+	}
+	sb.WriteString(`</trkseg></trk></gpx>`)
+	data := sb.String()
+	return &scan.File{
+		Name:    name,
+		Ext:     filepath.Ext(name),
+		Size:    int64(len(data)),
+		ModTime: start,
+		Opener:  func() (io.Reader, error) { return strings.NewReader(data), nil },
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	is := require.New(t)
+	ctx := context.Background()
+
+	ix := New()
+	a, b := gpxFile("a.gpx", ts0, 80, 1), gpxFile("b.gpx", ts0.Add(24*time.Hour), 80, 1)
+	c := &scan.File{Name: "c.txt", Ext: ".txt"}
+	changes, err := ix.Update(ctx, []*scan.File{a, b, c}, nil)
+	is.NoError(err)
+	is.Equal(3, changes.Added)
+	is.Equal(2, changes.Activities)
+	is.Len(changes.Diagnostics, 3)
+	is.Equal(parse.Unsupported, ix.Files["c.txt"].Outcome)
+
+	b = gpxFile("b.gpx", ts0.Add(48*time.Hour), 80, 1)
+	changes, err = ix.Update(ctx, []*scan.File{b, c}, nil)
+	is.NoError(err)
+	is.Equal(&Changes{Changed: 1, Removed: 1, Unchanged: 1, Activities: 1, Diagnostics: changes.Diagnostics}, changes)
+	is.Len(changes.Diagnostics, 1)
+	is.Equal("b.gpx", changes.Diagnostics[0].File)
+	is.Equal(ts0.Add(48*time.Hour), ix.Files["b.gpx"].Activities[0].Start)
+}
+
+func TestUpdateDuplicates(t *testing.T) {
+	is := require.New(t)
+
+	ix := New()
+	files := []*scan.File{
+		gpxFile("strava/1.gpx", ts0, 82, 2),
+		gpxFile("garmin/1.gpx", ts0.Add(time.Second), 80, 1),
+		gpxFile("garmin/2.gpx", ts0.Add(30*time.Second), 20, 1),
+	}
+	changes, err := ix.Update(context.Background(), files, nil)
+	is.NoError(err)
+	is.Equal(2, changes.Activities)
+	is.Equal(1, changes.Duplicates)
+	is.Equal(ix.Files["strava/1.gpx"].Activities[0].ID, ix.Files["garmin/1.gpx"].Activities[0].DuplicateOf)
+	is.Len(ix.Activities(), 3)
+
+	testCases := []struct {
+		policy string
+		expect []string
+	}{
+		{"longest", []string{"garmin/2.gpx", "strava/1.gpx"}},
+		{"sensors", []string{"garmin/1.gpx", "garmin/2.gpx"}},
+	}
+	for _, testCase := range testCases {
+		acts, _, _, err := parse.Select(ix.Activities(), &parse.Selector{}, &parse.Cleaner{KeepOutliers: true, KeepDuplicate: testCase.policy})
+		is.NoError(err)
+		files := make([]string, len(acts))
+		for i, act := range acts {
+			files[i] = act.File
+		}
+		is.Equal(testCase.expect, files, testCase.policy)
+	}
+}
+
+func TestSaveOpen(t *testing.T) {
+	is := require.New(t)
+	name := filepath.Join(t.TempDir(), "activities.idx")
+
+	_, err := Open(name)
+	is.ErrorIs(err, os.ErrNotExist)
+
+	ix := New()
+	_, err = ix.Update(context.Background(), []*scan.File{gpxFile("a.gpx", ts0, 80, 1)}, nil)
+	is.NoError(err)
+	is.NoError(ix.Save(name))
+
+	got, err := Open(name)
+	is.NoError(err)
+	is.Len(got.Files, 1)
+	is.True(got.Files["a.gpx"].ModTime.Equal(ts0))
+
+	want, gotActs := ix.Activities(), got.Activities()
+	is.Len(gotActs, 1)
+	is.Equal(want[0].Distance, gotActs[0].Distance)
+	is.Equal(want[0].ElevationGain, gotActs[0].ElevationGain)
+	is.Len(gotActs[0].Records, len(want[0].Records))
+	is.True(want[0].Records[0].Timestamp.Equal(gotActs[0].Records[0].Timestamp))
+
+	ix.Version = formatVersion + 1
+	is.NoError(ix.Save(name))
+	_, err = Open(name)
+	is.ErrorIs(err, ErrVersion)
+}
+
+func TestSimplify(t *testing.T) {
+	is := require.New(t)
+
+	var recs []*parse.Record
+	for i := 0; i <= 100; i++ {
+		lat := -37.8
+		if i == 50 {
+			lat += 0.001
+		}
+		recs = append(recs, &parse.Record{Position: [2]float64{144.9 + 0.0001*float64(i), lat}})
+	}
+	is.Equal([]int{0, 49, 50, 51, 100}, simplify(recs, simplifyTolerance))
+	is.Equal([]int{0, 1}, simplify(recs[:2], simplifyTolerance))
+}
@@ -0,0 +1,70 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/output"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"golang.org/x/text/language"
+)
+
+type Options struct {
+	Input    []string
+	Output   string
+	Report   string
+	Locale   language.Tag
+	Jobs     int
+	Progress progress.Reporter
+	Cache    *parse.Cache
+}
+
+// Run creates or incrementally updates the index at the output path with the activities found in the input paths.
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
+	if len(o.Input) == 0 {
+		o.Input = []string{"."}
+	}
+	if o.Output == "" {
+		o.Output = "activities.idx"
+	}
+	printer := locale.NewPrinter(o.Locale, locale.Metric)
+
+	ix, err := Open(o.Output)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrVersion) {
+		ix = New()
+	} else if err != nil {
+		return err
+	}
+
+	files, err := scan.Scan(ctx, o.Input, o.Progress)
+	if err != nil {
+		return err
+	}
+	printer.Field("files", "%d", len(files))
+
+	changes, err := ix.Update(ctx, files, &parse.Options{Jobs: o.Jobs, Progress: o.Progress, Cache: o.Cache})
+	if err != nil {
+		return err
+	}
+	changes.Diagnostics.Print(printer)
+	printer.Field("added", "%d", changes.Added)
+	printer.Field("changed", "%d", changes.Changed)
+	printer.Field("removed", "%d", changes.Removed)
+	printer.Field("unchanged", "%d", changes.Unchanged)
+	printer.Field("activities", "%d", changes.Activities)
+	if changes.Duplicates > 0 {
+		printer.Field("duplicates", "%d", changes.Duplicates)
+	}
+	if o.Report != "" {
+		if err := output.Create(o.Report, changes.Diagnostics.WriteJSON); err != nil {
+			return err
+		}
+	}
+
+	return ix.Save(o.Output)
+}
@@ -0,0 +1,90 @@
+package locale
+
+import (
+	"golang.org/x/text/language"
+	"golang.org/x/text/message/catalog"
+)
+
+// translations maps printed labels and formats to their translation in each supported language.
+var translations = map[language.Tag]map[string]string{
+	language.German: {
+		// stats
+		"activities":    "Aktivitäten",
+		"records":       "Messpunkte",
+		"sports":        "Sportarten",
+		"period":        "Zeitraum",
+		"duration":      "Dauer",
+		"moving time":   "Bewegungszeit",
+		"distance":      "Distanz",
+		"pace":          "Pace",
+		"elevation":     "Höhe",
+		"heart rate":    "Herzfrequenz",
+		"cadence":       "Trittfrequenz",
+		"power":         "Leistung",
+		"speed":         "Tempo",
+		"bounds":        "Grenzen",
+		"starts within": "Start in",
+		"ends within":   "Ende in",
+
+		"%d, dropped %d outliers":        "%d, %d Ausreißer verworfen",
+		"%s to %s, average %s, total %s": "%s bis %s, Schnitt %s, gesamt %s",
+		"%s to %s, average %s":           "%s bis %s, Schnitt %s",
+		"%s to %s, gain %s":              "%s bis %s, Anstieg %s",
+		"average %s, total %s":           "Schnitt %s, gesamt %s",
+		"average %s, max %s":             "Schnitt %s, max. %s",
+		"average %.0fbpm, max %.0fbpm":   "Schnitt %.0fbpm, max. %.0fbpm",
+		"average %.0frpm, max %.0frpm":   "Schnitt %.0frpm, max. %.0frpm",
+		"average %.0fW, max %.0fW":       "Schnitt %.0fW, max. %.0fW",
+
+		// files and diagnostics
+		"files":       "Dateien",
+		"progress":    "Fortschritt",
+		"parsed":      "gelesen",
+		"filtered":    "gefiltert",
+		"duplicate":   "Duplikat",
+		"empty":       "leer",
+		"unsupported": "nicht lesbar",
+		"corrupt":     "beschädigt",
+
+		// index
+		"added":      "hinzugefügt",
+		"changed":    "geändert",
+		"removed":    "entfernt",
+		"unchanged":  "unverändert",
+		"duplicates": "Duplikate",
+
+		// stats table
+		"elevation gain": "Höhenmeter",
+		"sport":          "Sportart",
+		"week":           "Woche",
+		"month":          "Monat",
+		"year":           "Jahr",
+		"weekday":        "Wochentag",
+		"total":          "gesamt",
+		"unknown":        "unbekannt",
+		"running":        "Laufen",
+		"cycling":        "Radfahren",
+		"walking":        "Gehen",
+		"hiking":         "Wandern",
+		"swimming":       "Schwimmen",
+		"Mon":            "Mo",
+		"Tue":            "Di",
+		"Wed":            "Mi",
+		"Thu":            "Do",
+		"Fri":            "Fr",
+		"Sat":            "Sa",
+		"Sun":            "So",
+	},
+}
+
+func newCatalog() *catalog.Builder {
+	b := catalog.NewBuilder(catalog.Fallback(language.English))
+	for tag, msgs := range translations {
+		for key, msg := range msgs {
+			if err := b.SetString(tag, key, msg); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return b
+}
@@ -0,0 +1,88 @@
+package locale
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+type Units string
+
+const (
+	Metric   Units = "metric"
+	Imperial Units = "imperial"
+
+	meters     = 1.0
+	kilometers = 1000.0
+	feet       = 0.3048
+	miles      = 1609.344
+
+	labelWidth = 15
+)
+
+var (
+	UnitSystems = []string{string(Metric), string(Imperial)}
+
+	// Catalog holds translations of printed labels and formats, keyed by their English text.
+	Catalog = newCatalog()
+)
+
+// Printer formats numbers for a locale and measurements for a system of units.
+type Printer struct {
+	*message.Printer
+	Units Units
+}
+
+func NewPrinter(tag language.Tag, units Units) *Printer {
+	if units == "" {
+		units = Metric
+	}
+	return &Printer{Printer: message.NewPrinter(tag, message.Catalog(Catalog)), Units: units}
+}
+
+// Field prints a translated label padded into a column followed by the formatted value.
+func (p *Printer) Field(label, format string, a ...any) {
+	label = p.Sprintf(label) + ":"
+	for n := utf8.RuneCountInString(label); n < labelWidth; n++ {
+		label += " "
+	}
+	p.Printf("%s%s\n", label, p.Sprintf(format, a...))
+}
+
+// Distance formats meters as kilometers or miles.
+func (p *Printer) Distance(m float64) string {
+	if p.Units == Imperial {
+		return p.Sprintf("%.1fmi", m/miles)
+	}
+	return p.Sprintf("%.1fkm", m/kilometers)
+}
+
+// Elevation formats meters as meters or feet.
+func (p *Printer) Elevation(m float64) string {
+	if p.Units == Imperial {
+		return p.Sprintf("%.0fft", m/feet)
+	}
+	return p.Sprintf("%.0fm", m/meters)
+}
+
+// Pace formats a duration per meter as time per kilometer or mile.
+func (p *Printer) Pace(pace time.Duration) string {
+	if p.Units == Imperial {
+		return p.Sprintf("%s/mi", time.Duration(float64(pace)*miles).Truncate(time.Second))
+	}
+	return p.Sprintf("%s/km", time.Duration(float64(pace)*kilometers).Truncate(time.Second))
+}
+
+// Speed formats meters per second as kilometers or miles per hour.
+func (p *Printer) Speed(mps float64) string {
+	if p.Units == Imperial {
+		return p.Sprintf("%.1fmph", mps*3600/miles)
+	}
+	return p.Sprintf("%.1fkm/h", mps*3600/kilometers)
+}
+
+func (p *Printer) Duration(dur time.Duration) string {
+	return p.Sprintf("%s", dur.Truncate(time.Second))
+}
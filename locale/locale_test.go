@@ -0,0 +1,57 @@
+package locale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestPrinter(t *testing.T) {
+	testCases := []struct {
+		tag                              language.Tag
+		units                            Units
+		distance, elevation, pace, speed string
+	}{
+		{language.English, Metric, "1,234.6km", "1,235m", "5m0s/km", "36.0km/h"},
+		{language.English, Imperial, "767.1mi", "4,051ft", "8m2s/mi", "22.4mph"},
+		{language.German, Metric, "1.234,6km", "1.235m", "5m0s/km", "36,0km/h"},
+		{language.English, "", "1,234.6km", "1,235m", "5m0s/km", "36.0km/h"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.tag.String()+" "+string(tc.units), func(t *testing.T) {
+			is := require.New(t)
+
+			p := NewPrinter(tc.tag, tc.units)
+			is.Equal(tc.distance, p.Distance(1_234_567))
+			is.Equal(tc.elevation, p.Elevation(1234.6))
+			is.Equal(tc.pace, p.Pace(300*time.Millisecond))
+			is.Equal(tc.speed, p.Speed(10))
+			is.Equal("1h2m3s", p.Duration(time.Hour+2*time.Minute+3*time.Second+400*time.Millisecond))
+		})
+	}
+}
+
+func TestPrinterTranslation(t *testing.T) {
+	testCases := []struct {
+		tag           language.Tag
+		label, format string
+	}{
+		{language.English, "activities", "1.0km to 2.0km, average 1.5km, total 3.0km"},
+		{language.German, "Aktivitäten", "1,0km bis 2,0km, Schnitt 1,5km, gesamt 3,0km"},
+		{language.MustParse("de-AT"), "Aktivitäten", "1,0km bis 2,0km, Schnitt 1,5km, gesamt 3,0km"},
+		{language.French, "activities", "1,0km to 2,0km, average 1,5km, total 3,0km"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.tag.String(), func(t *testing.T) {
+			is := require.New(t)
+
+			p := NewPrinter(tc.tag, Metric)
+			is.Equal(tc.label, p.Sprintf("activities"))
+			is.Equal(tc.format, p.Sprintf("%s to %s, average %s, total %s", p.Distance(1000), p.Distance(2000), p.Distance(1500), p.Distance(3000)))
+		})
+	}
+}
@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
+	"os/signal"
 	"strings"
 
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
 	"github.com/spf13/cobra"
 )
 
 const Title = "rainbow-roads"
 
-var Version string
+var (
+	Version string
+	noCache bool
+)
 
 var rootCmd = &cobra.Command{
 	Use:               Title,
@@ -23,7 +31,40 @@ func main() {
 	if _, _, err := rootCmd.Find(os.Args[1:]); err != nil && strings.HasPrefix(err.Error(), "unknown command ") {
 		rootCmd.SetArgs(append([]string{wormsCmd.Name()}, os.Args[1:]...))
 	}
-	if rootCmd.Execute() != nil {
+
+	// the first interrupt cancels the run cleanly, a second one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		os.Exit(1)
 	}
 }
+
+// withProgress runs fn with a progress bar drawn on standard error, or with no reporter when standard error isn't a terminal.
+func withProgress(fn func(rep progress.Reporter) error) error {
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return fn(nil)
+	}
+	bar := progress.NewBar(os.Stderr)
+	defer bar.Clear()
+	return fn(bar)
+}
+
+// activityCache returns the persistent cache of parsed activities, or nil when disabled or unavailable.
+func activityCache() *parse.Cache {
+	if noCache {
+		return nil
+	}
+	dir, err := parse.DefaultCacheDir()
+	if err != nil {
+		log.Println("WARN:", err)
+		return nil
+	}
+	return parse.NewCache(dir)
+}
@@ -0,0 +1,40 @@
+package output
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Create writes a file by passing it to fn, first to a temporary file in the same directory that
+// replaces name only once fn succeeds, so failed or canceled runs leave no partial output behind.
+func Create(name string, fn func(w io.Writer) error) (err error) {
+	dir := filepath.Dir(name)
+	if dir != "." {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if err = fn(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(0o644); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,37 @@
+package output
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	is := require.New(t)
+	dir := t.TempDir()
+
+	name := filepath.Join(dir, "sub", "out.txt")
+	is.NoError(Create(name, func(w io.Writer) error {
+		_, err := io.WriteString(w, "hello")
+		return err
+	}))
+	data, err := os.ReadFile(name)
+	is.NoError(err)
+	is.Equal("hello", string(data))
+
+	is.ErrorContains(Create(name, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("boom")
+	}), "boom")
+	data, err = os.ReadFile(name)
+	is.NoError(err)
+	is.Equal("hello", string(data), "existing file untouched")
+
+	entries, err := os.ReadDir(filepath.Dir(name))
+	is.NoError(err)
+	is.Len(entries, 1, "temporary file removed")
+}
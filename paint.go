@@ -2,29 +2,60 @@ package main
 
 import (
 	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/NathanBaulch/rainbow-roads/locale"
 	"github.com/NathanBaulch/rainbow-roads/paint"
+	"github.com/NathanBaulch/rainbow-roads/progress"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/language"
 )
 
 var (
 	paintOpts = &paint.Options{
-		Title:   Title,
-		Version: Version,
+		Title:     Title,
+		Version:   Version,
+		Timeout:   3 * time.Minute,
+		Threshold: 25,
+		Units:     locale.Metric,
+		Locale:    language.English,
 	}
 	paintCmd = &cobra.Command{
 		Use:   "paint",
 		Short: "Track coverage in a region of interest",
-		PreRunE: func(*cobra.Command, []string) error {
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			if paintOpts.Index != "" && len(args) > 0 {
+				return flagError("index", paintOpts.Index, "not supported with input paths")
+			}
+			if paintOpts.Jobs < 0 {
+				return flagError("jobs", paintOpts.Jobs, "must not be negative")
+			}
 			if paintOpts.Width == 0 {
 				return flagError("width", paintOpts.Width, "must be positive")
 			}
-			return nil
+			if paintOpts.StreetReport != "" && !slices.Contains(paint.ReportFormats, strings.ToLower(filepath.Ext(paintOpts.StreetReport))) {
+				return flagError("street_report", paintOpts.StreetReport, "format not supported")
+			}
+			if paintOpts.Threshold == 0 {
+				return flagError("threshold", paintOpts.Threshold, "must be positive")
+			}
+			if u, err := url.Parse(paintOpts.OverpassURL); err != nil || u.Scheme == "" || u.Host == "" {
+				return flagError("overpass_url", paintOpts.OverpassURL, "not a valid URL")
+			}
+			return validateCleaner(&paintOpts.Cleaner)
 		},
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			paintOpts.Input = args
-			return paint.Run(paintOpts)
+			paintOpts.Cache = activityCache()
+			return withProgress(func(rep progress.Reporter) error {
+				paintOpts.Progress = rep
+				return paint.Run(cmd.Context(), paintOpts)
+			})
 		},
 	}
 )
@@ -35,16 +66,32 @@ func init() {
 	general := &pflag.FlagSet{}
 	general.VarP(&GeometryFlag{Geometry: &paintOpts.Region}, "region", "r", "target region of interest, eg circle(-37.8,144.9,10km)")
 	general.StringVarP(&paintOpts.Output, "output", "o", "out", "optional path of the generated file")
-	general.VisitAll(func(f *pflag.Flag) { paintCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+	general.StringVar(&paintOpts.StreetReport, "street_report", "", "optional path of a per-street coverage report, supports csv, json")
+	general.StringVar(&paintOpts.GeoJSON, "geojson", "", "optional path of a GeoJSON file of done, partial and pending streets along with the region")
+	general.StringVar(&paintOpts.OSMFile, "osm_file", "", "local OpenStreetMap .osm.pbf or .osm extract to use instead of the Overpass API")
+	general.StringVar(&paintOpts.OverpassURL, "overpass_url", "https://overpass-api.de/api/interpreter", "Overpass API endpoint used to download road data")
+	general.Var((*DurationFlag)(&paintOpts.Timeout), "overpass_timeout", "longest time to wait for each Overpass API request, eg 5m")
+	general.UintVar(&paintOpts.Retries, "overpass_retries", 3, "number of retries when the Overpass API is busy")
+	general.Var((*UnitsFlag)(&paintOpts.Units), "units", "system of units used to print measurements, supports "+strings.Join(locale.UnitSystems, ", ")+"; JSON and CSV output is always metric")
+	general.Var((*LocaleFlag)(&paintOpts.Locale), "locale", "language used to print numbers and labels, eg de, en-US")
+	general.StringVar(&paintOpts.Index, "index", "", "optional path of an activity index to query instead of scanning input files")
+	general.StringVar(&paintOpts.Report, "report", "", "optional path of a JSON report listing the outcome of every scanned file")
+	general.IntVar(&paintOpts.Jobs, "jobs", 0, "number of files parsed concurrently, defaults to the number of CPUs")
+	general.BoolVar(&noCache, "no_cache", false, "disable the cache of parsed activities kept in the user cache directory")
+	general.VisitAll(paintCmd.Flags().AddFlag)
 	_ = paintCmd.MarkFlagRequired("region")
 
 	rendering := &pflag.FlagSet{}
 	rendering.UintVarP(&paintOpts.Width, "width", "w", 1000, "width of the generated image in pixels")
+	rendering.Var((*DistanceFlag)(&paintOpts.Threshold), "threshold", "distance from activities within which streets are considered covered, eg 30m")
 	rendering.BoolVar(&paintOpts.NoWatermark, "no_watermark", false, "suppress the embedded project name and version string")
-	rendering.VisitAll(func(f *pflag.Flag) { paintCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+	rendering.VisitAll(paintCmd.Flags().AddFlag)
 
 	filters := filterFlagSet(&paintOpts.Selector)
-	filters.VisitAll(func(f *pflag.Flag) { paintCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+	filters.VisitAll(paintCmd.Flags().AddFlag)
+
+	cleaning := cleanFlagSet(&paintOpts.Cleaner)
+	cleaning.VisitAll(paintCmd.Flags().AddFlag)
 
 	paintCmd.SetUsageFunc(func(*cobra.Command) error {
 		fmt.Fprintln(paintCmd.OutOrStderr())
@@ -55,6 +102,8 @@ func init() {
 		fmt.Fprintln(paintCmd.OutOrStderr(), general.FlagUsages())
 		fmt.Fprintln(paintCmd.OutOrStderr(), "Filtering flags:")
 		fmt.Fprintln(paintCmd.OutOrStderr(), filters.FlagUsages())
+		fmt.Fprintln(paintCmd.OutOrStderr(), "Cleaning flags:")
+		fmt.Fprintln(paintCmd.OutOrStderr(), cleaning.FlagUsages())
 		fmt.Fprintln(paintCmd.OutOrStderr(), "Rendering flags:")
 		fmt.Fprint(paintCmd.OutOrStderr(), rendering.FlagUsages())
 		return nil
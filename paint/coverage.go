@@ -0,0 +1,116 @@
+package paint
+
+import (
+	"math"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/paulmach/orb"
+	orbgeo "github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/planar"
+)
+
+const earthRadius = 6_371_000
+
+type cover struct {
+	length, covered float64
+}
+
+// measureCoverage splits each way into short pieces and tests them against a spatial index of activity tracks,
+// returning the length of each way within the region and how much of it lies within the threshold of an activity.
+func measureCoverage(ways []*way, acts []*parse.Activity, region geo.Geometry, threshold float64) []cover {
+	origin := region.Bound().Center()
+	cosLat := math.Cos(geo.DegreesToRadians(origin.Lat()))
+	proj := func(pt orb.Point) orb.Point {
+		return orb.Point{
+			geo.DegreesToRadians(pt.Lon()-origin.Lon()) * cosLat * earthRadius,
+			geo.DegreesToRadians(pt.Lat()-origin.Lat()) * earthRadius,
+		}
+	}
+
+	idx := indexTracks(acts, orbgeo.BoundPad(region.Bound(), threshold), proj, threshold)
+
+	step := threshold / 5
+	covers := make([]cover, len(ways))
+	for i, w := range ways {
+		for j := 1; j < len(w.Geometry); j++ {
+			g0, g1 := w.Geometry[j-1], w.Geometry[j]
+			p0, p1 := proj(g0), proj(g1)
+			length := planar.Distance(p0, p1)
+			n := max(math.Ceil(length/step), 1)
+			for k := 0.0; k < n; k++ {
+				f := (k + 0.5) / n
+				if !region.Contains(interpolate(g0, g1, f)) {
+					continue
+				}
+				covers[i].length += length / n
+				if idx.near(interpolate(p0, p1, f), threshold) {
+					covers[i].covered += length / n
+				}
+			}
+		}
+	}
+	return covers
+}
+
+// indexTracks builds a segment index of the activity tracks, leaving out segments entirely outside the bound
+// so that the index grows with the painted area rather than the whole activity history.
+func indexTracks(acts []*parse.Activity, bound orb.Bound, proj func(orb.Point) orb.Point, size float64) *segmentIndex {
+	idx := newSegmentIndex(size)
+	for _, a := range acts {
+		for i := 1; i < len(a.Records); i++ {
+			p0, p1 := a.Records[i-1].Position, a.Records[i].Position
+			if bound.Intersects(orb.Bound{Min: p0, Max: p0}.Extend(p1)) {
+				idx.insert(proj(p0), proj(p1))
+			}
+		}
+	}
+	return idx
+}
+
+func interpolate(p0, p1 orb.Point, f float64) orb.Point {
+	return orb.Point{p0[0] + (p1[0]-p0[0])*f, p0[1] + (p1[1]-p0[1])*f}
+}
+
+// segmentIndex is a uniform grid of line segments in planar meters.
+type segmentIndex struct {
+	size  float64
+	cells map[[2]int][][2]orb.Point
+	seen  map[[2]int]bool
+}
+
+func newSegmentIndex(size float64) *segmentIndex {
+	return &segmentIndex{size: size, cells: make(map[[2]int][][2]orb.Point), seen: make(map[[2]int]bool)}
+}
+
+func (s *segmentIndex) cell(pt orb.Point) [2]int {
+	return [2]int{int(math.Floor(pt[0] / s.size)), int(math.Floor(pt[1] / s.size))}
+}
+
+// insert adds the segment to every cell within two cell sizes of points sampled along it,
+// ensuring it can be found from anywhere within one cell size.
+func (s *segmentIndex) insert(a, b orb.Point) {
+	n := max(math.Ceil(planar.Distance(a, b)/s.size), 1)
+	clear(s.seen)
+	for k := 0.0; k <= n; k++ {
+		c := s.cell(interpolate(a, b, k/n))
+		for x := c[0] - 2; x <= c[0]+2; x++ {
+			for y := c[1] - 2; y <= c[1]+2; y++ {
+				if c := [2]int{x, y}; !s.seen[c] {
+					s.seen[c] = true
+					s.cells[c] = append(s.cells[c], [2]orb.Point{a, b})
+				}
+			}
+		}
+	}
+}
+
+// near reports whether any segment lies within the given distance of the point, which must not exceed the cell size.
+func (s *segmentIndex) near(pt orb.Point, dist float64) bool {
+	for _, seg := range s.cells[s.cell(pt)] {
+		if planar.DistanceFromSegmentSquared(seg[0], seg[1], pt) <= dist*dist {
+			return true
+		}
+	}
+	return false
+}
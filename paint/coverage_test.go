@@ -0,0 +1,65 @@
+package paint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureCoverage(t *testing.T) {
+	is := require.New(t)
+
+	// roughly 1.11m per 0.00001 degrees at the equator
+	ways := []*way{
+		{Geometry: []orb.Point{{0, 0}, {0.0018, 0}}},
+		{Geometry: []orb.Point{{0, 0.0005}, {0.0018, 0.0005}}},
+		{Geometry: []orb.Point{{0.1, 0}, {0.1018, 0}}},
+	}
+	act := &parse.Activity{Records: []*parse.Record{
+		{Timestamp: time.Unix(0, 0), Position: orb.Point{-0.0005, 0.00009}},
+		{Timestamp: time.Unix(60, 0), Position: orb.Point{0.0009, 0.00009}},
+	}}
+	region := geo.Circle{Origin: orb.Point{0.0009, 0}, Radius: 1000}
+
+	covers := measureCoverage(ways, []*parse.Activity{act}, region, 25)
+	is.Len(covers, 3)
+	is.InDelta(200, covers[0].length, 1)
+	is.InDelta(123, covers[0].covered, 3)
+	is.InDelta(200, covers[1].length, 1)
+	is.Zero(covers[1].covered)
+	is.Zero(covers[2].length)
+	is.Zero(covers[2].covered)
+}
+
+func TestIndexTracks(t *testing.T) {
+	is := require.New(t)
+
+	rec := func(lon, lat float64) *parse.Record { return &parse.Record{Position: orb.Point{lon, lat}} }
+	acts := []*parse.Activity{
+		{Records: []*parse.Record{rec(5, 5), rec(5.01, 5), rec(5.02, 5)}},
+		{Records: []*parse.Record{rec(-1, 0.5), rec(2, 0.5)}},
+	}
+	proj := func(pt orb.Point) orb.Point { return orb.Point{pt[0] * 100, pt[1] * 100} }
+
+	idx := indexTracks(acts, orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{1, 1}}, proj, 10)
+	is.True(idx.near(orb.Point{50, 50}, 10))
+	is.False(idx.near(orb.Point{501, 500}, 10))
+	for _, segs := range idx.cells {
+		is.Len(segs, 1)
+	}
+}
+
+func TestSegmentIndex(t *testing.T) {
+	is := require.New(t)
+
+	idx := newSegmentIndex(10)
+	idx.insert(orb.Point{0, 0}, orb.Point{1000, 1000})
+	is.True(idx.near(orb.Point{500, 507}, 10))
+	is.False(idx.near(orb.Point{500, 515}, 10))
+	is.True(idx.near(orb.Point{1007, 1000}, 10))
+	is.False(idx.near(orb.Point{-8, -8}, 10))
+}
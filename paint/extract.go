@@ -0,0 +1,110 @@
+package paint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/expr-lang/expr"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/osm"
+	"github.com/paulmach/osm/osmpbf"
+	"github.com/paulmach/osm/osmxml"
+)
+
+type osmScanner interface {
+	Scan() bool
+	Object() osm.Object
+	Err() error
+	Close() error
+}
+
+// extractLookup reads ways from a local OSM extract, evaluating the filter against their tags in-process
+// rather than translating it into an Overpass query.
+func extractLookup(ctx context.Context, name string, region geo.Geometry, filter string) ([]*way, error) {
+	program, err := expr.Compile(filter,
+		expr.AsBool(),
+		expr.AllowUndefinedVariables(),
+		expr.Function("is_tag", func(params ...any) (any, error) { return params[0] != nil, nil }),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("osm filter error: %w", err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var scanner osmScanner
+	if strings.HasSuffix(strings.ToLower(name), ".pbf") {
+		s := osmpbf.New(ctx, f, runtime.GOMAXPROCS(0))
+		s.SkipRelations = true
+		scanner = s
+	} else {
+		scanner = osmxml.New(ctx, f)
+	}
+	defer scanner.Close()
+
+	nodes := make(map[osm.NodeID]orb.Point)
+	var ways []*way
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		switch obj := scanner.Object().(type) {
+		case *osm.Node:
+			nodes[obj.ID] = orb.Point{obj.Lon, obj.Lat}
+		case *osm.Way:
+			env := make(map[string]any, len(obj.Tags))
+			for _, t := range obj.Tags {
+				env[t.Key] = t.Value
+			}
+			if res, err := expr.Run(program, env); err != nil {
+				return nil, fmt.Errorf("osm filter error: %w", err)
+			} else if !res.(bool) {
+				continue
+			}
+
+			w := &way{
+				ID:       int64(obj.ID),
+				Name:     obj.Tags.Find("name"),
+				Ref:      obj.Tags.Find("ref"),
+				Geometry: make([]orb.Point, 0, len(obj.Nodes)),
+				Highway:  obj.Tags.Find("highway"),
+				Access:   obj.Tags.Find("access"),
+				Surface:  obj.Tags.Find("surface"),
+			}
+			inside := false
+			for _, n := range obj.Nodes {
+				pt, ok := nodes[n.ID]
+				if !ok {
+					if n.Lat == 0 && n.Lon == 0 {
+						continue
+					}
+					pt = orb.Point{n.Lon, n.Lat}
+				}
+				w.Geometry = append(w.Geometry, pt)
+				if !inside && region.Contains(pt) {
+					inside = true
+				}
+			}
+			if inside {
+				ways = append(ways, w)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return ways, nil
+}
@@ -0,0 +1,77 @@
+package paint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+const testExtract = `
+		<osm version="0.6">
+		  <node id="1" lat="1.000" lon="2.000"/>
+		  <node id="2" lat="1.001" lon="2.001"/>
+		  <node id="3" lat="1.002" lon="2.002"/>
+		  <node id="4" lat="5.000" lon="6.000"/>
+		  <node id="5" lat="5.001" lon="6.001"/>
+		  <way id="10">
+		    <nd ref="1"/><nd ref="2"/><nd ref="3"/>
+		    <tag k="highway" v="residential"/>
+		    <tag k="surface" v="asphalt"/>
+		  </way>
+		  <way id="11">
+		    <nd ref="1"/><nd ref="2"/>
+		    <tag k="highway" v="steps"/>
+		  </way>
+		  <way id="12">
+		    <nd ref="2"/><nd ref="3"/>
+		    <tag k="highway" v="service"/>
+		    <tag k="service" v="driveway"/>
+		  </way>
+		  <way id="13">
+		    <nd ref="1"/><nd ref="3"/>
+		    <tag k="building" v="yes"/>
+		  </way>
+		  <way id="14">
+		    <nd ref="4"/><nd ref="5"/>
+		    <tag k="highway" v="primary"/>
+		  </way>
+		  <way id="15">
+		    <nd ref="3"/><nd ref="4"/>
+		    <tag k="highway" v="track"/>
+		    <tag k="access" v="permissive"/>
+		  </way>
+		</osm>`
+
+func TestExtractLookup(t *testing.T) {
+	is := require.New(t)
+
+	name := filepath.Join(t.TempDir(), "extract.osm")
+	is.NoError(os.WriteFile(name, []byte(testExtract), 0o666))
+
+	ways, err := extractLookup(context.Background(), name, geo.Circle{Origin: orb.Point{2, 1}, Radius: 1000}, queryExpr)
+	is.NoError(err)
+	is.Len(ways, 2)
+	is.Equal("residential", ways[0].Highway)
+	is.Equal("asphalt", ways[0].Surface)
+	is.Equal([]orb.Point{{2, 1}, {2.001, 1.001}, {2.002, 1.002}}, ways[0].Geometry)
+	is.Equal("track", ways[1].Highway)
+	is.Equal("permissive", ways[1].Access)
+	is.Len(ways[1].Geometry, 2)
+}
+
+func TestExtractLookupCanceled(t *testing.T) {
+	is := require.New(t)
+
+	name := filepath.Join(t.TempDir(), "extract.osm")
+	is.NoError(os.WriteFile(name, []byte(testExtract), 0o666))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := extractLookup(ctx, name, geo.Circle{Origin: orb.Point{2, 1}, Radius: 1000}, queryExpr)
+	is.ErrorIs(err, context.Canceled)
+}
@@ -0,0 +1,100 @@
+package paint
+
+import (
+	"io"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/paulmach/orb"
+	orbgeo "github.com/paulmach/orb/geo"
+	"github.com/paulmach/orb/geojson"
+)
+
+// buildGeoJSON collects the ways within the region as line features, tagged with their coverage status,
+// followed by the region itself as a polygon feature.
+func buildGeoJSON(ways []*way, covers []cover, region geo.Geometry) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for i, w := range ways {
+		c := covers[i]
+		if c.length == 0 {
+			continue
+		}
+		fraction := round(c.covered/c.length, 4)
+		status := "partial"
+		if fraction >= 1 {
+			status = "done"
+		} else if fraction == 0 {
+			status = "pending"
+		}
+
+		f := geojson.NewFeature(orb.LineString(w.Geometry))
+		f.ID = w.ID
+		f.Properties["way_id"] = w.ID
+		if w.Name != "" {
+			f.Properties["name"] = w.Name
+		}
+		if w.Ref != "" {
+			f.Properties["ref"] = w.Ref
+		}
+		f.Properties["highway"] = w.Highway
+		f.Properties["status"] = status
+		f.Properties["covered"] = fraction
+		f.Properties["length"] = round(c.length, 1)
+		fc.Append(f)
+	}
+
+	f := geojson.NewFeature(regionGeometry(region))
+	f.Properties["region"] = region.String()
+	fc.Append(f)
+	return fc
+}
+
+// regionGeometry returns the region as a polygon, or a collection of the parts of combined regions.
+func regionGeometry(region geo.Geometry) orb.Geometry {
+	var geoms []geo.Geometry
+	switch r := region.(type) {
+	case geo.Polygon:
+		return r.Rings
+	case geo.MultiPolygon:
+		return r.Polygons
+	case geo.Union:
+		geoms = r.Geometries
+	case geo.Intersection:
+		geoms = r.Geometries
+	case geo.Difference:
+		geoms = r.Geometries
+	default:
+		return orb.Polygon{regionRing(region)}
+	}
+	coll := make(orb.Collection, len(geoms))
+	for i, g := range geoms {
+		coll[i] = regionGeometry(g)
+	}
+	return coll
+}
+
+// regionRing returns the closed outline of the region, approximating circles with a polygon.
+func regionRing(region geo.Geometry) orb.Ring {
+	var ring orb.Ring
+	if c, ok := region.(geo.Circle); ok {
+		const segments = 64
+		ring = make(orb.Ring, segments, segments+1)
+		for i := range ring {
+			ring[i] = orbgeo.PointAtBearingAndDistance(c.Origin, 360*float64(i)/segments, c.Radius)
+		}
+	} else {
+		ring = append(orb.Ring{}, region.Ring()...)
+	}
+	if len(ring) > 0 && !ring.Closed() {
+		ring = append(ring, ring[0])
+	}
+	return ring
+}
+
+func writeGeoJSON(w io.Writer, fc *geojson.FeatureCollection) error {
+	data, err := fc.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
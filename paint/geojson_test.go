@@ -0,0 +1,71 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildGeoJSON(t *testing.T) {
+	is := require.New(t)
+
+	ways := []*way{
+		{ID: 1, Name: "Done Street", Highway: "residential", Geometry: []orb.Point{{0, 0}, {1, 0}}},
+		{ID: 2, Name: "Half Street", Ref: "C3", Highway: "residential", Geometry: []orb.Point{{0, 1}, {1, 1}}},
+		{ID: 3, Highway: "service", Geometry: []orb.Point{{0, 2}, {1, 2}}},
+		{ID: 4, Highway: "service", Geometry: []orb.Point{{5, 5}, {6, 6}}},
+	}
+	covers := []cover{{100, 100}, {200, 50}, {50, 0}, {0, 0}}
+	fc := buildGeoJSON(ways, covers, geo.Circle{Origin: orb.Point{0.5, 1}, Radius: 1000})
+
+	data, err := fc.MarshalJSON()
+	is.NoError(err)
+	fc, err = geojson.UnmarshalFeatureCollection(data)
+	is.NoError(err)
+	is.Len(fc.Features, 4)
+
+	is.Equal("done", fc.Features[0].Properties["status"])
+	is.Equal("Done Street", fc.Features[0].Properties["name"])
+	is.Equal(orb.LineString{{0, 0}, {1, 0}}, fc.Features[0].Geometry)
+	is.Equal("partial", fc.Features[1].Properties["status"])
+	is.Equal(0.25, fc.Features[1].Properties["covered"])
+	is.Equal("C3", fc.Features[1].Properties["ref"])
+	is.Equal("pending", fc.Features[2].Properties["status"])
+	is.NotContains(fc.Features[2].Properties, "name")
+
+	region := fc.Features[3]
+	is.Equal("circle(1,0.5,1000)", region.Properties["region"])
+	poly, ok := region.Geometry.(orb.Polygon)
+	is.True(ok)
+	is.Len(poly[0], 65)
+	is.True(poly[0].Closed())
+	for _, pt := range poly[0] {
+		is.InDelta(1000, geo.DistanceHaversine(orb.Point{0.5, 1}, pt), 5)
+	}
+}
+
+func TestRegionRingSquare(t *testing.T) {
+	is := require.New(t)
+
+	sq := geo.NewSquare(orb.Point{1, 2}, 1000, 0)
+	ring := regionRing(sq)
+	is.Len(ring, 5)
+	is.True(ring.Closed())
+	is.Len(sq.Ring(), 4)
+}
+
+func TestBuildGeoJSONPolygon(t *testing.T) {
+	is := require.New(t)
+
+	rings := orb.Polygon{
+		{{0, 0}, {4, 0}, {4, 4}, {0, 4}, {0, 0}},
+		{{1, 1}, {3, 1}, {3, 3}, {1, 3}, {1, 1}},
+	}
+	fc := buildGeoJSON(nil, nil, geo.Polygon{Rings: rings, Source: "zone.kml"})
+	is.Len(fc.Features, 1)
+	is.Equal(rings, fc.Features[0].Geometry)
+	is.Equal("file(zone.kml)", fc.Features[0].Properties["region"])
+}
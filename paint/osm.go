@@ -1,13 +1,18 @@
 package paint
 
 import (
+	"context"
 	"errors"
 	"hash/fnv"
 	"log"
 	"math"
 	"math/big"
+	"net/http"
+	"net/url"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/paulmach/orb"
@@ -17,16 +22,77 @@ import (
 )
 
 type way struct {
+	ID       int64
+	Name     string
+	Ref      string
 	Geometry []orb.Point
 	Highway  string
 	Access   string
 	Surface  string
 }
 
-const ttl = 168 * time.Hour
+func (w *way) isPrimary() bool {
+	env := map[string]string{
+		"highway": w.Highway,
+		"access":  w.Access,
+		"surface": w.Surface,
+	}
+	return mustRun(primaryExpr, env).(bool)
+}
+
+const (
+	ttl = 168 * time.Hour
+	// cacheVersion invalidates cached ways when their packed format changes.
+	cacheVersion = "2"
+)
+
+// overpassClient posts queries to an Overpass endpoint, retrying with exponential backoff when the server is busy.
+// The context is held on the client since the overpass library only accepts a PostForm-style poster.
+type overpassClient struct {
+	ctx      context.Context
+	endpoint string
+	client   *http.Client
+	retries  uint
+	backoff  time.Duration
+}
+
+func (c *overpassClient) PostForm(url string, data url.Values) (*http.Response, error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	backoff := c.backoff
+	for attempt := uint(0); ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := c.client.Do(req)
+		if err != nil || attempt >= c.retries ||
+			(resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusGatewayTimeout) {
+			return resp, err
+		}
+		_ = resp.Body.Close()
+
+		wait := backoff
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			wait = time.Duration(secs) * time.Second
+		}
+		log.Printf("WARN: overpass responded %s, retrying in %s\n", resp.Status, wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
 
-func osmLookup(query string) ([]*way, error) {
+func osmLookup(c *overpassClient, query string) ([]*way, error) {
 	h := fnv.New64()
+	_, _ = h.Write([]byte(cacheVersion))
+	_, _ = h.Write([]byte(c.endpoint))
 	_, _ = h.Write([]byte(query))
 	name := path.Join(os.TempDir(), "rainbow-roads")
 	if err := os.MkdirAll(name, 0o777); err != nil {
@@ -46,7 +112,8 @@ func osmLookup(query string) ([]*way, error) {
 		}
 	}
 
-	if res, err := overpass.Query(query); err != nil {
+	client := overpass.NewWithSettings(c.endpoint, 1, c)
+	if res, err := client.Query(query); err != nil {
 		return nil, err
 	} else if data, err := packWays(res.Ways); err != nil {
 		return nil, err
@@ -62,6 +129,9 @@ func packWays(ways map[int64]*overpass.Way) ([]byte, error) {
 
 	i := 0
 	for _, w := range ways {
+		d.Ways[i].ID = w.ID
+		d.Ways[i].Name = w.Tags["name"]
+		d.Ways[i].Ref = w.Tags["ref"]
 		d.Ways[i].Geometry = make([][2]float32, len(w.Geometry))
 		for j, g := range w.Geometry {
 			d.Ways[i].Geometry[j][0] = float32(g.Lat)
@@ -97,7 +167,12 @@ func unpackWays(data []byte) ([]*way, error) {
 
 	ways := make([]*way, len(d.Ways))
 	for i, w := range d.Ways {
-		ways[i] = &way{Geometry: make([]orb.Point, len(w.Geometry))}
+		ways[i] = &way{
+			ID:       w.ID,
+			Name:     w.Name,
+			Ref:      w.Ref,
+			Geometry: make([]orb.Point, len(w.Geometry)),
+		}
 		for j, p := range w.Geometry {
 			ways[i].Geometry[j][1] = float64(p[0])
 			ways[i].Geometry[j][0] = float64(p[1])
@@ -134,6 +209,9 @@ type doc struct {
 }
 
 type elem struct {
+	ID       int64        `msgpack:"i"`
+	Name     string       `msgpack:"n,omitempty"`
+	Ref      string       `msgpack:"r,omitempty"`
 	Geometry [][2]float32 `msgpack:"g"`
 	Highway  uint8        `msgpack:"h"`
 	Access   uint8        `msgpack:"a"`
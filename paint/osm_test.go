@@ -1,7 +1,9 @@
 package paint
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/paulmach/orb"
@@ -15,7 +17,10 @@ func TestPackUnpackWays(t *testing.T) {
 	in := map[int64]*overpass.Way{
 		0: {
 			Meta: overpass.Meta{
+				ID: 123,
 				Tags: map[string]string{
+					"name":    "Main Street",
+					"ref":     "A1",
 					"highway": "primary",
 					"access":  "public",
 					"surface": "paved",
@@ -32,7 +37,38 @@ func TestPackUnpackWays(t *testing.T) {
 	is.Len(out, 1)
 	is.Len(out[0].Geometry, 1)
 	is.True((geo.Circle{Origin: out[0].Geometry[0], Radius: 0.002}).Contains(orb.Point{2, 1}))
+	is.Equal(int64(123), out[0].ID)
+	is.Equal("Main Street", out[0].Name)
+	is.Equal("A1", out[0].Ref)
 	is.Equal("primary", out[0].Highway)
 	is.Equal("public", out[0].Access)
 	is.Equal("paved", out[0].Surface)
 }
+
+func TestOSMLookupRetries(t *testing.T) {
+	is := require.New(t)
+	t.Setenv("TMPDIR", t.TempDir())
+
+	fake, srv := newFakeOverpass(t, "testdata/overpass.json", http.StatusTooManyRequests, http.StatusGatewayTimeout)
+	c := &overpassClient{endpoint: srv.URL, client: srv.Client(), retries: 2, backoff: time.Millisecond}
+	ways, err := osmLookup(c, "[out:json];way;out tags geom qt;")
+	is.NoError(err)
+	is.Len(ways, 3)
+	is.Len(fake.queries, 3)
+
+	ways, err = osmLookup(c, "[out:json];way;out tags geom qt;")
+	is.NoError(err)
+	is.Len(ways, 3)
+	is.Len(fake.queries, 3)
+}
+
+func TestOSMLookupRetriesExhausted(t *testing.T) {
+	is := require.New(t)
+	t.Setenv("TMPDIR", t.TempDir())
+
+	fake, srv := newFakeOverpass(t, "testdata/overpass.json", http.StatusGatewayTimeout, http.StatusGatewayTimeout)
+	c := &overpassClient{endpoint: srv.URL, client: srv.Client(), retries: 1, backoff: time.Millisecond}
+	_, err := osmLookup(c, "[out:json];way;out tags geom qt;")
+	is.ErrorContains(err, "504")
+	is.Len(fake.queries, 2)
+}
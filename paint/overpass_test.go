@@ -0,0 +1,55 @@
+package paint
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+// fakeOverpass stands in for the Overpass API, answering every query with fixture JSON
+// after first responding with any queued failure status codes.
+type fakeOverpass struct {
+	fixture  []byte
+	failures []int
+	queries  []string
+	mu       sync.Mutex
+}
+
+func newFakeOverpass(t *testing.T, fixture string, failures ...int) (*fakeOverpass, *httptest.Server) {
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeOverpass{fixture: data, failures: failures}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeOverpass) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.PostFormValue("data")
+	if query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.queries = append(f.queries, query)
+
+	if len(f.failures) > 0 {
+		status := f.failures[0]
+		f.failures = f.failures[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(f.fixture)
+}
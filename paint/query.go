@@ -2,6 +2,7 @@ package paint
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -11,52 +12,186 @@ import (
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/expr-lang/expr/ast"
 	"github.com/expr-lang/expr/builtin"
+	"github.com/expr-lang/expr/conf"
 	"github.com/expr-lang/expr/parser"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/clip"
 	"golang.org/x/exp/slices"
 )
 
-func init() {
-	clear(builtin.Builtins)
-	clear(builtin.Index)
-	clear(builtin.Names)
-	builtin.Builtins = []*builtin.Function{{Name: "is_tag"}}
-	builtin.Index["is_tag"] = 0
-	builtin.Names[0] = "is_tag"
+// parseFilter parses a way filter with every expr builtin disabled, so calls pass through as Overpass functions,
+// except for is_tag which is parsed as the only builtin. The global builtin tables are left untouched since
+// other packages such as parse rely on them.
+func parseFilter(filter string) (*parser.Tree, error) {
+	disabled := make(map[string]bool, len(builtin.Names))
+	for _, name := range builtin.Names {
+		disabled[name] = true
+	}
+	tree, err := parser.ParseWithConfig(filter, &conf.Config{Disabled: disabled})
+	if err != nil {
+		return nil, err
+	}
+	ast.Walk(&tree.Node, isTagBuiltin{})
+	return tree, nil
+}
+
+type isTagBuiltin struct{}
+
+func (isTagBuiltin) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.CallNode); ok && n.Callee.String() == "is_tag" {
+		b := &ast.BuiltinNode{Name: "is_tag", Arguments: n.Arguments}
+		b.SetLocation(n.Location())
+		*node = b
+	}
 }
 
 func buildQuery(region geo.Geometry, filter string) (string, error) {
 	if crits, err := buildCriteria(filter); err != nil {
 		return "", fmt.Errorf("overpass query error: %w", err)
 	} else {
-		var prefix string
-		if c, ok := region.(geo.Circle); ok {
-			prefix = fmt.Sprintf("way(around:%s,%s,%s)",
-				conv.FormatFloat(c.Radius),
-				conv.FormatFloat(c.Origin.Lat()),
-				conv.FormatFloat(c.Origin.Lon()),
-			)
-		} else {
-			r := region.Ring()
-			parts := make([]string, 0, len(r)*3+2)
+		prefixes := regionFilters(region)
+		parts := make([]string, 0, len(prefixes)*len(crits)*3+2)
+		parts = append(parts, "[out:json];(")
+		for _, prefix := range prefixes {
+			for _, crit := range crits {
+				parts = append(parts, prefix, crit, ";")
+			}
+		}
+		parts = append(parts, ");out tags geom qt;")
+		return strings.Join(parts, ""), nil
+	}
+}
+
+// regionFilters returns Overpass filters that together select at least every way within the region.
+// Intersections and differences are approximated by their smallest and first geometries respectively,
+// with ways outside the exact region excluded later when measuring coverage.
+func regionFilters(region geo.Geometry) []string {
+	var filters []string
+	switch r := region.(type) {
+	case geo.Circle:
+		filters = append(filters, fmt.Sprintf("way(around:%s,%s,%s)",
+			conv.FormatFloat(r.Radius),
+			conv.FormatFloat(r.Origin.Lat()),
+			conv.FormatFloat(r.Origin.Lon()),
+		))
+	case geo.BBox:
+		filters = append(filters, fmt.Sprintf("way(%s,%s,%s,%s)",
+			conv.FormatFloat(r.Box.Bottom()),
+			conv.FormatFloat(r.Box.Left()),
+			conv.FormatFloat(r.Box.Top()),
+			conv.FormatFloat(r.Box.Right()),
+		))
+	case geo.Corridor:
+		parts := make([]string, 0, len(r.Path)*2+1)
+		parts = append(parts, conv.FormatFloat(r.Buffer))
+		for _, pt := range r.Path {
+			parts = append(parts, conv.FormatFloat(pt.Lat()), conv.FormatFloat(pt.Lon()))
+		}
+		filters = append(filters, fmt.Sprintf("way(around:%s)", strings.Join(parts, ",")))
+	case geo.Union:
+		for _, g := range r.Geometries {
+			filters = append(filters, regionFilters(g)...)
+		}
+	case geo.Intersection:
+		smallest, area := geo.Geometry(nil), math.Inf(1)
+		for _, g := range r.Geometries {
+			b := g.Bound()
+			if a := (b.Right() - b.Left()) * (b.Top() - b.Bottom()); a < area {
+				smallest, area = g, a
+			}
+		}
+		if smallest != nil {
+			filters = regionFilters(smallest)
+		}
+	case geo.Difference:
+		if len(r.Geometries) > 0 {
+			filters = regionFilters(r.Geometries[0])
+		}
+	default:
+		for _, r := range splitRings(regionOutlines(region), 0) {
+			parts := make([]string, 0, len(r)*4+2)
 			parts = append(parts, `way(poly:"`)
-			for _, pt := range r {
+			for i, pt := range r {
+				if i > 0 {
+					parts = append(parts, " ")
+				}
 				parts = append(parts, conv.FormatFloat(pt.Lat()), " ", conv.FormatFloat(pt.Lon()))
 			}
 			parts = append(parts, `")`)
-			prefix = strings.Join(parts, "")
+			filters = append(filters, strings.Join(parts, ""))
 		}
-		parts := make([]string, 0, len(crits)*3+2)
-		parts = append(parts, "[out:json];(")
-		for _, crit := range crits {
-			parts = append(parts, prefix, crit, ";")
+	}
+	return filters
+}
+
+// maxPolyPoints limits the size of each Overpass poly filter, keeping requests well within server limits.
+const maxPolyPoints = 500
+
+// regionOutlines returns the outer ring of every polygon in the region, ignoring holes since
+// ways within them are excluded later when measuring coverage.
+func regionOutlines(region geo.Geometry) []orb.Ring {
+	switch g := region.(type) {
+	case geo.Polygon:
+		return []orb.Ring{g.Ring()}
+	case geo.MultiPolygon:
+		rings := make([]orb.Ring, 0, len(g.Polygons))
+		for _, p := range g.Polygons {
+			if len(p) > 0 {
+				rings = append(rings, p[0])
+			}
 		}
-		parts = append(parts, ");out tags geom qt;")
-		return strings.Join(parts, ""), nil
+		return rings
+	default:
+		return []orb.Ring{region.Ring()}
+	}
+}
+
+// regionRings returns every ring in the region including holes, suitable for filling with the even-odd rule.
+func regionRings(region geo.Geometry) []orb.Ring {
+	switch g := region.(type) {
+	case geo.Polygon:
+		return g.Rings
+	case geo.MultiPolygon:
+		var rings []orb.Ring
+		for _, p := range g.Polygons {
+			rings = append(rings, p...)
+		}
+		return rings
+	default:
+		return []orb.Ring{region.Ring()}
+	}
+}
+
+// splitRings recursively clips rings with too many points into halves along their longest axis.
+func splitRings(rings []orb.Ring, depth int) []orb.Ring {
+	var res []orb.Ring
+	for _, r := range rings {
+		if len(r) > 0 && r.Closed() {
+			r = r[:len(r)-1]
+		}
+		if len(r) <= maxPolyPoints || depth >= 16 {
+			if len(r) >= 3 {
+				res = append(res, r)
+			}
+			continue
+		}
+
+		b := r.Bound()
+		b0, b1 := b, b
+		if b.Right()-b.Left() > b.Top()-b.Bottom() {
+			mid := (b.Left() + b.Right()) / 2
+			b0.Max[0], b1.Min[0] = mid, mid
+		} else {
+			mid := (b.Bottom() + b.Top()) / 2
+			b0.Max[1], b1.Min[1] = mid, mid
+		}
+		res = append(res, splitRings([]orb.Ring{clip.Ring(b0, r.Clone()), clip.Ring(b1, r.Clone())}, depth+1)...)
 	}
+	return res
 }
 
 func buildCriteria(filter string) ([]string, error) {
-	tree, err := parser.Parse(filter)
+	tree, err := parseFilter(filter)
 	if err != nil {
 		return nil, err
 	}
@@ -32,6 +32,88 @@ func TestBuildQuery(t *testing.T) {
 	}
 }
 
+func TestBuildQueryPolygon(t *testing.T) {
+	is := require.New(t)
+
+	region := geo.MultiPolygon{Polygons: orb.MultiPolygon{
+		{{{1, 2}, {3, 2}, {3, 4}, {1, 2}}, {{2, 2.5}, {2.5, 2.5}, {2.5, 3}, {2, 2.5}}},
+		{{{5, 6}, {7, 6}, {7, 8}, {5, 6}}},
+	}}
+	got, err := buildQuery(region, "is_tag(highway)")
+	is.NoError(err)
+	is.Equal(`[out:json];(way(poly:"2 1 2 3 4 3")[highway];way(poly:"6 5 6 7 8 7")[highway];);out tags geom qt;`, got)
+}
+
+func TestBuildQueryBBox(t *testing.T) {
+	is := require.New(t)
+
+	got, err := buildQuery(geo.BBox{Box: orb.Bound{Min: orb.Point{1, 2}, Max: orb.Point{3, 4}}}, "is_tag(highway)")
+	is.NoError(err)
+	is.Equal(`[out:json];(way(2,1,4,3)[highway];);out tags geom qt;`, got)
+}
+
+func TestBuildQueryCorridor(t *testing.T) {
+	is := require.New(t)
+
+	got, err := buildQuery(geo.Corridor{Path: orb.LineString{{1, 2}, {3, 4}, {5, 6}}, Buffer: 50}, "is_tag(highway)")
+	is.NoError(err)
+	is.Equal(`[out:json];(way(around:50,2,1,4,3,6,5)[highway];);out tags geom qt;`, got)
+}
+
+func TestBuildQueryCombined(t *testing.T) {
+	testCases := []struct {
+		region geo.Geometry
+		want   string
+	}{
+		{
+			geo.Union{Geometries: []geo.Geometry{geo.Circle{Origin: orb.Point{1, 2}, Radius: 3}, geo.BBox{Box: orb.Bound{Min: orb.Point{4, 5}, Max: orb.Point{6, 7}}}}},
+			`[out:json];(way(around:3,2,1)[highway];way(5,4,7,6)[highway];);out tags geom qt;`,
+		},
+		{
+			geo.Intersection{Geometries: []geo.Geometry{geo.BBox{Box: orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{6, 7}}}, geo.Circle{Origin: orb.Point{1, 2}, Radius: 3}}},
+			`[out:json];(way(around:3,2,1)[highway];);out tags geom qt;`,
+		},
+		{
+			geo.Difference{Geometries: []geo.Geometry{geo.BBox{Box: orb.Bound{Min: orb.Point{0, 0}, Max: orb.Point{6, 7}}}, geo.Circle{Origin: orb.Point{1, 2}, Radius: 3}}},
+			`[out:json];(way(0,0,7,6)[highway];);out tags geom qt;`,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			got, err := buildQuery(tc.region, "is_tag(highway)")
+			is.NoError(err)
+			is.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestSplitRings(t *testing.T) {
+	is := require.New(t)
+
+	ring := make(orb.Ring, 0, 2*maxPolyPoints+1)
+	for i := 0; i < maxPolyPoints; i++ {
+		ring = append(ring, orb.Point{float64(i), 0})
+	}
+	for i := maxPolyPoints - 1; i >= 0; i-- {
+		ring = append(ring, orb.Point{float64(i), 1})
+	}
+	ring = append(ring, ring[0])
+
+	rings := splitRings([]orb.Ring{ring}, 0)
+	is.Len(rings, 4)
+	total := 0
+	for _, r := range rings {
+		is.LessOrEqual(len(r), maxPolyPoints)
+		is.False(r.Closed())
+		total += len(r)
+	}
+	is.GreaterOrEqual(total, 2*maxPolyPoints)
+	is.Len(splitRings([]orb.Ring{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}}, 0), 1)
+}
+
 func TestBuildCriteria(t *testing.T) {
 	testCases := []struct{ input, want string }{
 		{"lit", `[lit="yes"]`},
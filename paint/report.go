@@ -0,0 +1,96 @@
+package paint
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+)
+
+var ReportFormats = []string{".csv", ".json"}
+
+type streetReport struct {
+	WayID   int64   `json:"way_id"`
+	Name    string  `json:"name,omitempty"`
+	Ref     string  `json:"ref,omitempty"`
+	Highway string  `json:"highway"`
+	Length  float64 `json:"length"`
+	Covered float64 `json:"covered"`
+	Percent float64 `json:"percent"`
+}
+
+// buildReport lists the ways within the region, unfinished streets closest to completion first.
+func buildReport(ways []*way, covers []cover) []streetReport {
+	rows := make([]streetReport, 0, len(ways))
+	for i, w := range ways {
+		c := covers[i]
+		if c.length == 0 {
+			continue
+		}
+		rows = append(rows, streetReport{
+			WayID:   w.ID,
+			Name:    w.Name,
+			Ref:     w.Ref,
+			Highway: w.Highway,
+			Length:  round(c.length, 1),
+			Covered: round(c.covered, 1),
+			Percent: round(100*c.covered/c.length, 2),
+		})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		r0, r1 := rows[i], rows[j]
+		if done0, done1 := r0.Percent >= 100, r1.Percent >= 100; done0 != done1 {
+			return done1
+		}
+		if r0.Percent != r1.Percent {
+			return r0.Percent > r1.Percent
+		}
+		if rem0, rem1 := r0.Length-r0.Covered, r1.Length-r1.Covered; rem0 != rem1 {
+			return rem0 < rem1
+		}
+		return r0.WayID < r1.WayID
+	})
+	return rows
+}
+
+func writeReport(w io.Writer, format string, rows []streetReport) error {
+	switch format = strings.ToLower(format); format {
+	case ".csv":
+		return writeReportCSV(w, rows)
+	case ".json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	default:
+		return fmt.Errorf("report format %q not supported", format)
+	}
+}
+
+func writeReportCSV(w io.Writer, rows []streetReport) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"way_id", "name", "ref", "highway", "length", "covered", "percent"})
+	for _, r := range rows {
+		_ = cw.Write([]string{
+			strconv.FormatInt(r.WayID, 10),
+			r.Name,
+			r.Ref,
+			r.Highway,
+			conv.FormatFloat(r.Length),
+			conv.FormatFloat(r.Covered),
+			conv.FormatFloat(r.Percent),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow10(places)
+	return math.Round(v*p) / p
+}
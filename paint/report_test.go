@@ -0,0 +1,35 @@
+package paint
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildReport(t *testing.T) {
+	is := require.New(t)
+
+	ways := []*way{
+		{ID: 1, Name: "Done Street", Highway: "residential"},
+		{ID: 2, Name: "Half Street", Highway: "residential"},
+		{ID: 3, Name: "Outside Street", Highway: "residential"},
+		{ID: 4, Name: "Nearly Street", Ref: "B2", Highway: "primary"},
+		{ID: 5, Highway: "service"},
+	}
+	covers := []cover{{100, 100}, {200, 100}, {0, 0}, {300, 270}, {50, 0}}
+	rows := buildReport(ways, covers)
+
+	ids := make([]int64, len(rows))
+	for i, r := range rows {
+		ids[i] = r.WayID
+	}
+	is.Equal([]int64{4, 2, 5, 1}, ids)
+	is.Equal(streetReport{WayID: 4, Name: "Nearly Street", Ref: "B2", Highway: "primary", Length: 300, Covered: 270, Percent: 90}, rows[0])
+
+	buf := &bytes.Buffer{}
+	is.NoError(writeReportCSV(buf, rows[:2]))
+	is.Equal("way_id,name,ref,highway,length,covered,percent\n"+
+		"4,Nearly Street,B2,primary,300,270,90\n"+
+		"2,Half Street,,residential,200,100,50\n", buf.String())
+}
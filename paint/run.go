@@ -1,19 +1,26 @@
 package paint
 
 import (
+	"context"
 	"errors"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"io/fs"
-	"log"
 	"math"
+	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/index"
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/output"
 	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
 	"github.com/NathanBaulch/rainbow-roads/scan"
 	"github.com/expr-lang/expr"
 	"github.com/fogleman/gg"
@@ -21,18 +28,9 @@ import (
 	"github.com/paulmach/orb/project"
 	"golang.org/x/image/colornames"
 	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
 var (
-	o          *Options
-	fullTitle  string
-	en         = message.NewPrinter(language.English)
-	files      []*scan.File
-	activities []*parse.Activity
-	roads      []*way
-	im         image.Image
-
 	backCol    = colornames.Black
 	donePriCol = colornames.Lime
 	doneSecCol = colornames.Green
@@ -50,24 +48,66 @@ var (
 			" and surface not in ['cobblestone','sett']", expr.AsBool())
 )
 
+var errNotRendered = errors.New("streets not rendered")
+
 type Options struct {
-	Title       string
-	Version     string
-	Input       []string
-	Output      string
-	Width       uint
-	Region      geo.Geometry
-	NoWatermark bool
-	Selector    parse.Selector
+	Title        string
+	Version      string
+	Input        []string
+	Index        string
+	Output       string
+	Report       string
+	Width        uint
+	Region       geo.Geometry
+	Threshold    float64
+	OSMFile      string
+	StreetReport string
+	GeoJSON      string
+	OverpassURL  string
+	Timeout      time.Duration
+	Retries      uint
+	NoWatermark  bool
+	Units        locale.Units
+	Locale       language.Tag
+	Jobs         int
+	Progress     progress.Reporter
+	Cache        *parse.Cache
+	Selector     parse.Selector
+	Cleaner      parse.Cleaner
+}
+
+// Renderer paints the streets of a region, highlighting those covered by activities.
+// All state is held per Renderer, including its own copies of the activities it selects,
+// so independent renderers may be used concurrently even with shared activities.
+type Renderer struct {
+	o          Options
+	fullTitle  string
+	activities []*parse.Activity
+	roads      []*way
+	covers     []cover
+}
+
+// Coverage is the length of primary streets in the region and how much of it has been covered, in meters.
+type Coverage struct {
+	Covered float64
+	Total   float64
 }
 
-func Run(opts *Options) error {
-	o = opts
-	fullTitle = "NathanBaulch/" + o.Title
-	if o.Version != "" {
-		fullTitle += " " + o.Version
+// NewRenderer creates a Renderer from a copy of the given options.
+func NewRenderer(opts *Options) *Renderer {
+	r := &Renderer{o: *opts}
+	if r.o.Threshold == 0 {
+		r.o.Threshold = 25
 	}
+	r.fullTitle = "NathanBaulch/" + r.o.Title
+	if r.o.Version != "" {
+		r.fullTitle += " " + r.o.Version
+	}
+	return r
+}
 
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
 	if len(o.Input) == 0 {
 		o.Input = []string{"."}
 	}
@@ -84,53 +124,168 @@ func Run(opts *Options) error {
 		o.Output += ".png"
 	}
 
-	for _, step := range []func() error{scanStep, parseStep, fetchStep, renderStep, saveStep} {
-		if err := step(); err != nil {
+	r := NewRenderer(&o)
+	printer := locale.NewPrinter(o.Locale, o.Units)
+
+	var (
+		stats *parse.Stats
+		diags parse.Diagnostics
+		err   error
+	)
+	if o.Index != "" {
+		var ix *index.Index
+		if ix, err = index.Open(o.Index); err != nil {
+			return err
+		}
+		printer.Field("files", "%d", len(ix.Files))
+		stats, diags, err = r.Select(ix.Activities())
+	} else {
+		var files []*scan.File
+		if files, err = scan.Scan(ctx, o.Input, o.Progress); err != nil {
 			return err
 		}
+		printer.Field("files", "%d", len(files))
+		stats, diags, err = r.Load(ctx, files)
+	}
+	if diags != nil {
+		diags.Print(printer)
+		if o.Report != "" {
+			if err := output.Create(o.Report, diags.WriteJSON); err != nil {
+				return err
+			}
+		}
+	}
+	if err != nil {
+		return err
 	}
+	stats.Print(printer)
 
+	im, cov, err := r.Render(ctx)
+	if err != nil {
+		return err
+	}
+	progress := 0.0
+	if cov.Total > 0 {
+		progress = cov.Covered / cov.Total
+	}
+	printer.Field("progress", "%.2f%% (%s of %s)", 100*progress, printer.Distance(cov.Covered), printer.Distance(cov.Total))
+
+	if err := output.Create(o.Output, func(w io.Writer) error { return png.Encode(w, im) }); err != nil {
+		return err
+	}
+	if o.StreetReport != "" {
+		if err := output.Create(o.StreetReport, func(w io.Writer) error {
+			return r.WriteStreetReport(w, filepath.Ext(o.StreetReport))
+		}); err != nil {
+			return err
+		}
+	}
+	if o.GeoJSON != "" {
+		if err := output.Create(o.GeoJSON, r.WriteGeoJSON); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func scanStep() error {
-	if f, err := scan.Scan(o.Input); err != nil {
-		return err
-	} else {
-		files = f
-		en.Println("files:        ", len(files))
-		return nil
+// Load parses the given files and returns their stats along with the outcome of each file.
+func (r *Renderer) Load(ctx context.Context, files []*scan.File) (*parse.Stats, parse.Diagnostics, error) {
+	activities, stats, diags, err := parse.Parse(ctx, files, &r.o.Selector, &r.o.Cleaner, &parse.Options{Jobs: r.o.Jobs, Progress: r.o.Progress, Cache: r.o.Cache})
+	if err != nil {
+		return nil, diags, err
 	}
+	r.activities = activities
+	return stats, diags, nil
 }
 
-func parseStep() error {
-	if a, stats, err := parse.Parse(files, &o.Selector); err != nil {
-		return err
-	} else {
-		activities = a
-		stats.Print(en)
-		return nil
+// Select uses previously decoded activities, such as those loaded from an index.
+func (r *Renderer) Select(acts []*parse.Activity) (*parse.Stats, parse.Diagnostics, error) {
+	activities, stats, diags, err := parse.Select(acts, &r.o.Selector, &r.o.Cleaner)
+	if err != nil {
+		return nil, diags, err
+	}
+	r.activities = activities
+	return stats, diags, nil
+}
+
+// Render fetches the streets of the region, paints them and measures their coverage.
+func (r *Renderer) Render(ctx context.Context) (image.Image, *Coverage, error) {
+	if r.o.Region == nil {
+		return nil, nil, errors.New("region not specified")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	progress.Report(r.o.Progress, progress.Fetch, 0, 1)
+	if err := r.fetch(ctx); err != nil {
+		return nil, nil, err
 	}
+	progress.Report(r.o.Progress, progress.Fetch, 1, 1)
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	im := r.draw()
+
+	r.covers = measureCoverage(r.roads, r.activities, r.o.Region, r.o.Threshold)
+	cov := &Coverage{}
+	for i, c := range r.covers {
+		if r.roads[i].isPrimary() {
+			cov.Total += c.length
+			cov.Covered += c.covered
+		}
+	}
+	return im, cov, nil
+}
+
+// WriteStreetReport writes the coverage of each street in the given format, one of ReportFormats.
+func (r *Renderer) WriteStreetReport(w io.Writer, format string) error {
+	if r.covers == nil {
+		return errNotRendered
+	}
+	return writeReport(w, format, buildReport(r.roads, r.covers))
+}
+
+// WriteGeoJSON writes the streets and their coverage status as a GeoJSON feature collection.
+func (r *Renderer) WriteGeoJSON(w io.Writer) error {
+	if r.covers == nil {
+		return errNotRendered
+	}
+	return writeGeoJSON(w, buildGeoJSON(r.roads, r.covers, r.o.Region))
 }
 
-func fetchStep() error {
-	query, err := buildQuery(o.Region.Grow(1/0.9), queryExpr)
+func (r *Renderer) fetch(ctx context.Context) error {
+	o := &r.o
+	region := o.Region.Grow(1 / 0.9)
+	if o.OSMFile != "" {
+		var err error
+		r.roads, err = extractLookup(ctx, o.OSMFile, region, queryExpr)
+		return err
+	}
+
+	query, err := buildQuery(region, queryExpr)
 	if err != nil {
 		return err
 	}
 
-	roads, err = osmLookup(query)
+	r.roads, err = osmLookup(&overpassClient{
+		ctx:      ctx,
+		endpoint: o.OverpassURL,
+		client:   &http.Client{Timeout: o.Timeout},
+		retries:  o.Retries,
+		backoff:  5 * time.Second,
+	}, query)
 	return err
 }
 
-func renderStep() error {
+func (r *Renderer) draw() image.Image {
+	o, activities, roads := &r.o, r.activities, r.roads
 	proj := project.WGS84.ToMercator
 	bound := o.Region.Bound()
 	origin := bound.Center()
 	scale := math.Cos(geo.DegreesToRadians(origin.Lat())) * 0.9 * float64(o.Width) / geo.BoundWidth(bound)
 	origin = project.Point(origin, proj)
 	offset := float64(o.Width) / 2
-	circle, _ := o.Region.(geo.Circle)
 
 	drawLine := func(gc *gg.Context, pt orb.Point) {
 		pt = project.Point(pt, proj)
@@ -141,8 +296,8 @@ func renderStep() error {
 	drawActs := func(gc *gg.Context, lineWidth float64) {
 		gc.SetLineWidth(1.3 * lineWidth * scale)
 		for _, a := range activities {
-			for _, r := range a.Records {
-				drawLine(gc, r.Position)
+			for _, rec := range a.Records {
+				drawLine(gc, rec.Position)
 			}
 			gc.Stroke()
 		}
@@ -160,12 +315,7 @@ func renderStep() error {
 		gc.SetStrokeStyle(gg.NewSolidPattern(strokeColor))
 
 		for _, w := range roads {
-			env := map[string]string{
-				"highway": w.Highway,
-				"access":  w.Access,
-				"surface": w.Surface,
-			}
-			if !primary || mustRun(primaryExpr, env).(bool) {
+			if !primary || w.isPrimary() {
 				lineWidth := 10.0
 				switch w.Highway {
 				case "motorway", "trunk", "primary", "secondary", "tertiary":
@@ -184,8 +334,46 @@ func renderStep() error {
 		}
 	}
 
+	drawRegion := func(gc *gg.Context) {
+		switch region := o.Region.(type) {
+		case geo.Circle:
+			gc.DrawCircle(offset, offset, 0.9*float64(o.Width)/2)
+			gc.Fill()
+		case geo.Corridor:
+			gc.SetLineWidth(2 * region.Buffer * scale / math.Cos(geo.DegreesToRadians(bound.Center().Lat())))
+			for _, pt := range region.Path {
+				drawLine(gc, pt)
+			}
+			gc.Stroke()
+		case geo.Union, geo.Intersection, geo.Difference:
+			for y := 0; y < int(o.Width); y++ {
+				start := -1
+				for x := 0; x <= int(o.Width); x++ {
+					pt := orb.Point{origin[0] + (float64(x)+0.5-offset)/scale, origin[1] - (float64(y)+0.5-offset)/scale}
+					if inside := x < int(o.Width) && region.Contains(project.Mercator.ToWGS84(pt)); inside && start < 0 {
+						start = x
+					} else if !inside && start >= 0 {
+						gc.DrawRectangle(float64(start), float64(y), float64(x-start), 1)
+						start = -1
+					}
+				}
+			}
+			gc.Fill()
+		default:
+			for _, ring := range regionRings(o.Region) {
+				gc.NewSubPath()
+				for _, pt := range ring {
+					drawLine(gc, pt)
+				}
+				gc.ClosePath()
+			}
+			gc.SetFillRule(gg.FillRuleEvenOdd)
+			gc.Fill()
+		}
+	}
+
 	maskGC := gg.NewContext(int(o.Width), int(o.Width))
-	drawActs(maskGC, 50)
+	drawActs(maskGC, 2*o.Threshold)
 	actMask := maskGC.AsMask()
 
 	_ = gc.SetMask(actMask)
@@ -197,16 +385,7 @@ func renderStep() error {
 	maskGC.SetColor(color.Transparent)
 	maskGC.Clear()
 	maskGC.SetColor(color.Black)
-	if circle.Radius != 0 {
-		maskGC.DrawCircle(offset, offset, 0.9*float64(o.Width)/2)
-	} else {
-		maskGC.NewSubPath()
-		for _, pt := range o.Region.Ring() {
-			drawLine(maskGC, pt)
-		}
-		maskGC.ClosePath()
-	}
-	maskGC.Fill()
+	drawRegion(maskGC)
 	_ = gc.SetMask(maskGC.AsMask())
 	drawWays(true, pendPriCol)
 
@@ -214,60 +393,13 @@ func renderStep() error {
 	maskGC.SetColor(color.Transparent)
 	maskGC.Clear()
 	maskGC.SetColor(color.Black)
-	if circle.Radius != 0 {
-		maskGC.DrawCircle(offset, offset, 0.9*float64(o.Width)/2)
-	} else {
-		maskGC.NewSubPath()
-		for _, pt := range o.Region.Ring() {
-			drawLine(maskGC, pt)
-		}
-		maskGC.ClosePath()
-	}
-	maskGC.Fill()
+	drawRegion(maskGC)
 	_ = gc.SetMask(maskGC.AsMask())
 	drawWays(true, donePriCol)
 
 	if !o.NoWatermark {
-		img.DrawWatermark(gc.Image(), fullTitle, pendSecCol)
-	}
-
-	done, pend := 0, 0
-	rect := gc.Image().Bounds()
-	for y := rect.Min.Y; y <= rect.Max.Y; y++ {
-		for x := rect.Min.X; x <= rect.Max.X; x++ {
-			switch gc.Image().At(x, y) {
-			case donePriCol:
-				done++
-			case pendPriCol:
-				pend++
-			}
-		}
-	}
-	if done == 0 && pend == 0 {
-		pend = 1
+		img.DrawWatermark(gc.Image(), r.fullTitle, pendSecCol)
 	}
-	en.Printf("progress:      %.2f%%\n", 100*float64(done)/float64(done+pend))
-
-	im = gc.Image()
-	return nil
-}
-
-func saveStep() error {
-	if dir := filepath.Dir(o.Output); dir != "." {
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			return err
-		}
-	}
-
-	out, err := os.Create(o.Output)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := out.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
 
-	return png.Encode(out, im)
+	return gc.Image()
 }
@@ -0,0 +1,148 @@
+package paint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	is := require.New(t)
+	t.Setenv("TMPDIR", t.TempDir())
+	dir := t.TempDir()
+
+	ts0 := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	sb := &strings.Builder{}
+	sb.WriteString(`<gpx><trk><type>running</type><trkseg>`)
+	for i := 0; i <= 80; i++ {
+		fmt.Fprintf(sb, `<trkpt lat="-37.8" lon="%.5f"><time>%s</time></trkpt>`, 144.896+0.0001*float64(i), ts0.Add(time.Duration(i)*3*time.Second).Format(time.RFC3339))
+	}
+	sb.WriteString(`</trkseg></trk></gpx>`)
+	is.NoError(os.WriteFile(filepath.Join(dir, "run.gpx"), []byte(sb.String()), 0o666))
+
+	fake, srv := newFakeOverpass(t, "testdata/overpass.json")
+	out := filepath.Join(dir, "paint.png")
+	is.NoError(Run(context.Background(), &Options{
+		Input:        []string{filepath.Join(dir, "run.gpx")},
+		Output:       out,
+		Width:        200,
+		Region:       geo.Circle{Origin: orb.Point{144.9, -37.8}, Radius: 300},
+		OverpassURL:  srv.URL,
+		StreetReport: filepath.Join(dir, "streets.json"),
+		GeoJSON:      filepath.Join(dir, "streets.geojson"),
+		Timeout:      time.Second,
+		NoWatermark:  true,
+	}))
+	is.Len(fake.queries, 1)
+	is.Contains(fake.queries[0], "way(around:333.33333,-37.8,144.9)")
+
+	f, err := os.Open(out)
+	is.NoError(err)
+	defer f.Close()
+	im, err := png.Decode(f)
+	is.NoError(err)
+	is.Equal(200, im.Bounds().Dx())
+
+	done, pend := 0, 0
+	for y := im.Bounds().Min.Y; y < im.Bounds().Max.Y; y++ {
+		for x := im.Bounds().Min.X; x < im.Bounds().Max.X; x++ {
+			switch color.RGBAModel.Convert(im.At(x, y)) {
+			case donePriCol:
+				done++
+			case pendPriCol:
+				pend++
+			}
+		}
+	}
+	is.Greater(done, 0)
+	is.Greater(pend, 0)
+
+	data, err := os.ReadFile(filepath.Join(dir, "streets.json"))
+	is.NoError(err)
+	var rows []streetReport
+	is.NoError(json.Unmarshal(data, &rows))
+	is.Len(rows, 3)
+	is.Equal(int64(103), rows[0].WayID)
+	is.Equal("North South Street", rows[1].Name)
+	is.Equal(int64(102), rows[1].WayID)
+	is.InDelta(600, rows[1].Length, 10)
+	is.Equal("East West Street", rows[2].Name)
+	is.Equal(100.0, rows[2].Percent)
+
+	data, err = os.ReadFile(filepath.Join(dir, "streets.geojson"))
+	is.NoError(err)
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	is.NoError(err)
+	is.Len(fc.Features, 4)
+}
+
+func TestRenderer(t *testing.T) {
+	is := require.New(t)
+	t.Setenv("TMPDIR", t.TempDir())
+
+	ts0 := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	sb := &strings.Builder{}
+	sb.WriteString(`<gpx><trk><type>running</type><trkseg>`)
+	for i := 0; i <= 80; i++ {
+		fmt.Fprintf(sb, `<trkpt lat="-37.8" lon="%.5f"><time>%s</time></trkpt>`, 144.896+0.0001*float64(i), ts0.Add(time.Duration(i)*3*time.Second).Format(time.RFC3339))
+	}
+	sb.WriteString(`</trkseg></trk></gpx>`)
+	data := sb.String()
+	files := []*scan.File{{Name: "run.gpx", Ext: ".gpx", Opener: func() (io.Reader, error) { return strings.NewReader(data), nil }}}
+
+	_, srv := newFakeOverpass(t, "testdata/overpass.json")
+	r := NewRenderer(&Options{
+		Width:       200,
+		Region:      geo.Circle{Origin: orb.Point{144.9, -37.8}, Radius: 300},
+		OverpassURL: srv.URL,
+		Timeout:     time.Second,
+		NoWatermark: true,
+	})
+	is.ErrorIs(r.WriteGeoJSON(io.Discard), errNotRendered)
+
+	stats, diags, err := r.Load(context.Background(), files)
+	is.NoError(err)
+	is.Len(diags, 1)
+	is.Equal(parse.Parsed, diags[0].Outcome)
+	is.Equal(1, stats.SportCounts["running"])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = r.Render(ctx)
+	is.ErrorIs(err, context.Canceled)
+
+	im, cov, err := r.Render(context.Background())
+	is.NoError(err)
+	is.Equal(200, im.Bounds().Dx())
+	is.Greater(cov.Covered, 0.0)
+	is.Greater(cov.Total, cov.Covered)
+
+	buf := &bytes.Buffer{}
+	is.NoError(r.WriteStreetReport(buf, ".json"))
+	var rows []streetReport
+	is.NoError(json.Unmarshal(buf.Bytes(), &rows))
+	is.Len(rows, 3)
+	is.ErrorContains(r.WriteStreetReport(io.Discard, ".xml"), "not supported")
+
+	buf.Reset()
+	is.NoError(r.WriteGeoJSON(buf))
+	fc, err := geojson.UnmarshalFeatureCollection(buf.Bytes())
+	is.NoError(err)
+	is.Len(fc.Features, 4)
+}
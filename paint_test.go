@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaintFlags(t *testing.T) {
+	is := require.New(t)
+
+	flags := paintCmd.Flags()
+	for short, name := range map[string]string{"r": "region", "o": "output", "w": "width"} {
+		f := flags.ShorthandLookup(short)
+		is.NotNil(f, short)
+		is.Equal(name, f.Name)
+	}
+	is.Equal("true", flags.Lookup("no_watermark").NoOptDefVal)
+	is.Equal("true", flags.Lookup("keep_outliers").NoOptDefVal)
+}
@@ -0,0 +1,181 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/output"
+	"github.com/paulmach/orb"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// parserVersion invalidates cached activities whenever decoding or the packed format changes.
+const parserVersion = "2"
+
+// Cache stores decoded activities on disk, keyed by a hash of the file contents.
+// Filtering and cleaning are applied after decoding, so cached activities are reused across options.
+type Cache struct {
+	dir string
+}
+
+// NewCache stores activities beneath the given directory, which is created as needed.
+func NewCache(dir string) *Cache {
+	return &Cache{dir: filepath.Join(dir, "v"+parserVersion)}
+}
+
+// DefaultCacheDir is the activity cache directory within the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "rainbow-roads", "activities"), nil
+}
+
+type cacheDoc struct {
+	Activities  []cacheActivity
+	Unsupported string
+	Error       string
+}
+
+type cacheActivity struct {
+	Name        string
+	Sport       string
+	Distance    float64
+	Duration    time.Duration
+	ElapsedTime time.Duration
+	MovingTime  time.Duration
+	Pauses      [][2]time.Time
+	Records     []cacheRecord
+}
+
+type cacheRecord struct {
+	Timestamp time.Time
+	Position  [2]float64
+	Elevation float64
+	HeartRate float64
+	Cadence   float64
+	Power     float64
+	Speed     float64
+}
+
+// decode returns the cached activities for the contents of r, otherwise decodes and caches them.
+// Decoding errors are cached too, since they depend only on the contents.
+func (c *Cache) decode(r io.Reader, ext string, decode func(io.Reader) ([]*Activity, error)) ([]*Activity, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h := fnv.New128a()
+	_, _ = h.Write([]byte(ext))
+	_, _ = h.Write(data)
+	name := filepath.Join(c.dir, big.NewInt(0).SetBytes(h.Sum(nil)).Text(62))
+
+	if packed, err := os.ReadFile(name); err == nil {
+		d := &cacheDoc{}
+		if err := msgpack.Unmarshal(packed, d); err != nil {
+			log.Println("WARN:", err)
+		} else {
+			return d.activities()
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Println("WARN:", err)
+	}
+
+	acts, err := decode(bytes.NewReader(data))
+	if packed, perr := msgpack.Marshal(newCacheDoc(acts, err)); perr != nil {
+		log.Println("WARN:", perr)
+	} else if perr := output.Create(name, func(w io.Writer) error {
+		_, err := w.Write(packed)
+		return err
+	}); perr != nil {
+		log.Println("WARN:", perr)
+	}
+	return acts, err
+}
+
+func newCacheDoc(acts []*Activity, err error) *cacheDoc {
+	d := &cacheDoc{Activities: make([]cacheActivity, len(acts))}
+	var uerr unsupportedError
+	if errors.As(err, &uerr) {
+		d.Unsupported = uerr.Error()
+	} else if err != nil {
+		d.Error = err.Error()
+	}
+
+	for i, act := range acts {
+		a := &d.Activities[i]
+		a.Name = act.Name
+		a.Sport = act.Sport
+		a.Distance = act.Distance
+		a.Duration = act.Duration
+		a.ElapsedTime = act.ElapsedTime
+		a.MovingTime = act.MovingTime
+		a.Pauses = make([][2]time.Time, len(act.Pauses))
+		for j, p := range act.Pauses {
+			a.Pauses[j] = [2]time.Time{p.Start, p.End}
+		}
+		a.Records = make([]cacheRecord, len(act.Records))
+		for j, r := range act.Records {
+			a.Records[j] = cacheRecord{
+				Timestamp: r.Timestamp,
+				Position:  r.Position,
+				Elevation: r.Elevation,
+				HeartRate: r.HeartRate,
+				Cadence:   r.Cadence,
+				Power:     r.Power,
+				Speed:     r.Speed,
+			}
+		}
+	}
+
+	return d
+}
+
+// activities returns the cached activities, or the cached decoding error.
+func (d *cacheDoc) activities() ([]*Activity, error) {
+	if d.Unsupported != "" {
+		return nil, unsupportedError(d.Unsupported)
+	}
+	if d.Error != "" {
+		return nil, errors.New(d.Error)
+	}
+
+	acts := make([]*Activity, len(d.Activities))
+	for i, a := range d.Activities {
+		act := &Activity{
+			Name:        a.Name,
+			Sport:       a.Sport,
+			Distance:    a.Distance,
+			ElapsedTime: a.ElapsedTime,
+			MovingTime:  a.MovingTime,
+			Duration:    a.Duration,
+			Pauses:      make([]Pause, len(a.Pauses)),
+			Records:     make([]*Record, len(a.Records)),
+		}
+		for j, p := range a.Pauses {
+			act.Pauses[j] = Pause{p[0], p[1]}
+		}
+		for j, r := range a.Records {
+			act.Records[j] = &Record{
+				Timestamp: r.Timestamp,
+				Position:  orb.Point(r.Position),
+				Elevation: r.Elevation,
+				HeartRate: r.HeartRate,
+				Cadence:   r.Cadence,
+				Power:     r.Power,
+				Speed:     r.Speed,
+			}
+		}
+		acts[i] = act
+	}
+	return acts, nil
+}
@@ -0,0 +1,94 @@
+package parse
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheDecode(t *testing.T) {
+	is := require.New(t)
+	dir := t.TempDir()
+
+	ts0 := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	want := &Activity{
+		Name:        "Morning Run",
+		Sport:       "running",
+		Distance:    1234.5,
+		ElapsedTime: 10 * time.Minute,
+		MovingTime:  9 * time.Minute,
+		Pauses:      []Pause{{ts0.Add(time.Minute), ts0.Add(2 * time.Minute)}},
+		Records:     []*Record{newRecord(ts0, orb.Point{144.9, -37.8}), newRecord(ts0.Add(10*time.Minute), orb.Point{144.91, -37.8})},
+		Duration:    9 * time.Minute,
+	}
+	want.Records[0].HeartRate = 140
+	calls := 0
+	decode := func(r io.Reader) ([]*Activity, error) {
+		calls++
+		data, _ := io.ReadAll(r)
+		if string(data) == "bad" {
+			return nil, errors.New("bad data")
+		}
+		if string(data) == "settings" {
+			return nil, unsupportedError("fit file type is settings")
+		}
+		return []*Activity{want}, nil
+	}
+
+	c := NewCache(dir)
+	for i := 0; i < 2; i++ {
+		acts, err := c.decode(strings.NewReader("good"), ".gpx", decode)
+		is.NoError(err)
+		is.Len(acts, 1)
+		got := acts[0]
+		is.Equal(want.Name, got.Name)
+		is.Equal(want.Distance, got.Distance)
+		is.Equal(want.Duration, got.Duration)
+		is.Equal(want.MovingTime, got.MovingTime)
+		is.Len(got.Pauses, 1)
+		is.True(want.Pauses[0].Start.Equal(got.Pauses[0].Start))
+		is.Len(got.Records, 2)
+		is.True(ts0.Equal(got.Records[0].Timestamp))
+		is.Equal(want.Records[1].Position, got.Records[1].Position)
+		is.Equal(140.0, got.Records[0].HeartRate)
+		is.True(math.IsNaN(got.Records[1].HeartRate))
+	}
+	is.Equal(1, calls)
+
+	_, err := c.decode(strings.NewReader("good"), ".tcx", decode)
+	is.NoError(err)
+	is.Equal(2, calls, "keyed by extension")
+
+	for i := 0; i < 2; i++ {
+		_, err = c.decode(strings.NewReader("bad"), ".gpx", decode)
+		is.EqualError(err, "bad data")
+		_, err = c.decode(strings.NewReader("settings"), ".fit", decode)
+		is.ErrorAs(err, new(unsupportedError))
+	}
+	is.Equal(4, calls, "errors cached")
+
+	entries, err := os.ReadDir(filepath.Join(dir, "v"+parserVersion))
+	is.NoError(err)
+	is.Len(entries, 4)
+
+	c = NewCache(t.TempDir())
+	calls = 0
+	_, err = c.decode(strings.NewReader("good"), ".gpx", decode)
+	is.NoError(err)
+	entries, err = os.ReadDir(c.dir)
+	is.NoError(err)
+	is.Len(entries, 1)
+	is.NoError(os.WriteFile(filepath.Join(c.dir, entries[0].Name()), []byte("junk"), 0o666))
+	acts, err := c.decode(strings.NewReader("good"), ".gpx", decode)
+	is.NoError(err)
+	is.Len(acts, 1)
+	is.Equal(2, calls, "unreadable entry decoded again")
+}
@@ -0,0 +1,194 @@
+package parse
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/paulmach/orb"
+)
+
+var Smoothers = []string{"none", "moving_average", "kalman"}
+
+// sportMaxSpeeds are the fastest plausible speeds in m/s, used when no explicit limit is given.
+var sportMaxSpeeds = map[string]float64{
+	"running":  12,
+	"walking":  6,
+	"hiking":   6,
+	"swimming": 5,
+	"cycling":  30,
+	"ebiking":  30,
+}
+
+const (
+	defaultMaxSpeed = 70
+	// maxDropRun is the number of consecutive outliers after which the track is assumed to have genuinely jumped.
+	maxDropRun = 10
+	// kalmanAccuracy is the assumed standard deviation of GPS fixes in meters.
+	kalmanAccuracy = 10
+	// kalmanSpeed is the assumed speed in m/s at which position uncertainty grows between fixes.
+	kalmanSpeed = 3
+)
+
+type Cleaner struct {
+	MaxSpeed     float64
+	KeepOutliers bool
+	Smoother     string
+	Window       uint
+	PrivacyZones []geo.Geometry
+	PrivacyTrim  float64
+	// KeepDuplicate is the policy choosing which copy of a duplicated activity is kept, defaulting to the longest.
+	KeepDuplicate string
+}
+
+// Clean removes records implying impossible speeds and the distance they added, trims the private ends of the
+// activity and optionally smooths the remaining positions, returning the number of outlier records dropped.
+func (c *Cleaner) Clean(act *Activity) int {
+	n := len(act.Records)
+	if !c.KeepOutliers {
+		recs := act.Records
+		act.Records = rejectOutliers(recs, c.maxSpeed(act.Sport))
+		if len(act.Records) < n {
+			act.Distance = max(act.Distance-(pathLength(recs)-pathLength(act.Records)), 0)
+		}
+	}
+	dropped := n - len(act.Records)
+	if len(c.PrivacyZones) > 0 || c.PrivacyTrim > 0 {
+		c.trimPrivate(act)
+	}
+	switch c.Smoother {
+	case "moving_average":
+		smoothMovingAverage(act.Records, int(max(c.Window, 1)))
+	case "kalman":
+		smoothKalman(act.Records)
+	}
+	return dropped
+}
+
+func (c *Cleaner) maxSpeed(sport string) float64 {
+	if c.MaxSpeed > 0 {
+		return c.MaxSpeed
+	}
+	if s, ok := sportMaxSpeeds[strings.ToLower(sport)]; ok {
+		return s
+	}
+	return defaultMaxSpeed
+}
+
+func (c *Cleaner) private(pt orb.Point) bool {
+	for _, z := range c.PrivacyZones {
+		if z.Contains(pt) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimPrivate removes records from both ends of the activity that fall within a privacy zone,
+// followed by the fixed trim distance, adjusting the total distance accordingly.
+func (c *Cleaner) trimPrivate(act *Activity) {
+	recs := act.Records
+	start := 0
+	for start < len(recs) && c.private(recs[start].Position) {
+		start++
+	}
+	for d := 0.0; start < len(recs) && d < c.PrivacyTrim; start++ {
+		if start+1 < len(recs) {
+			d += geo.DistanceHaversine(recs[start].Position, recs[start+1].Position)
+		}
+	}
+	end := len(recs)
+	for end > start && c.private(recs[end-1].Position) {
+		end--
+	}
+	for d := 0.0; end > start && d < c.PrivacyTrim; end-- {
+		if end-2 >= start {
+			d += geo.DistanceHaversine(recs[end-2].Position, recs[end-1].Position)
+		}
+	}
+	trimmed := 0.0
+	for i := 1; i < len(recs); i++ {
+		if i <= start || i >= end {
+			trimmed += geo.DistanceHaversine(recs[i-1].Position, recs[i].Position)
+		}
+	}
+	act.Records = recs[start:end]
+	act.Distance = max(act.Distance-trimmed, 0)
+}
+
+func pathLength(recs []*Record) float64 {
+	d := 0.0
+	for i := 1; i < len(recs); i++ {
+		d += geo.DistanceHaversine(recs[i-1].Position, recs[i].Position)
+	}
+	return d
+}
+
+func rejectOutliers(recs []*Record, maxSpeed float64) []*Record {
+	implied := func(r0, r1 *Record) float64 {
+		dt := max(r1.Timestamp.Sub(r0.Timestamp), time.Second)
+		return geo.DistanceHaversine(r0.Position, r1.Position) / dt.Seconds()
+	}
+
+	// drifting fixes at the start are only recognizable in hindsight
+	start := 0
+	for start < len(recs)-1 && start < maxDropRun && implied(recs[start], recs[start+1]) > maxSpeed {
+		start++
+	}
+	if start == maxDropRun {
+		start = 0
+	}
+
+	kept := make([]*Record, 0, len(recs)-start)
+	run := 0
+	for _, r := range recs[start:] {
+		if len(kept) > 0 && run < maxDropRun && implied(kept[len(kept)-1], r) > maxSpeed {
+			run++
+			continue
+		}
+		kept = append(kept, r)
+		run = 0
+	}
+	return kept
+}
+
+func smoothMovingAverage(recs []*Record, window int) {
+	half := window / 2
+	pts := make([]orb.Point, len(recs))
+	for i := range recs {
+		lo, hi := max(i-half, 0), min(i+half+1, len(recs))
+		var sum orb.Point
+		for _, r := range recs[lo:hi] {
+			sum[0] += r.Position[0]
+			sum[1] += r.Position[1]
+		}
+		pts[i] = orb.Point{sum[0] / float64(hi-lo), sum[1] / float64(hi-lo)}
+	}
+	for i, r := range recs {
+		r.Position = pts[i]
+	}
+}
+
+// smoothKalman applies a constant position Kalman filter, with uncertainty in square meters shared by both axes.
+func smoothKalman(recs []*Record) {
+	const variance = kalmanAccuracy * kalmanAccuracy
+	var pos orb.Point
+	p := math.NaN()
+	var ts time.Time
+	for _, r := range recs {
+		if math.IsNaN(p) {
+			pos, p, ts = r.Position, variance, r.Timestamp
+			continue
+		}
+		if dt := r.Timestamp.Sub(ts).Seconds(); dt > 0 {
+			p += dt * kalmanSpeed * kalmanSpeed
+			ts = r.Timestamp
+		}
+		k := p / (p + variance)
+		pos[0] += k * (r.Position[0] - pos[0])
+		pos[1] += k * (r.Position[1] - pos[1])
+		p *= 1 - k
+		r.Position = pos
+	}
+}
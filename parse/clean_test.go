@@ -0,0 +1,125 @@
+package parse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+func cleanRecords(lons ...float64) []*Record {
+	ts0 := time.Date(2022, 2, 13, 0, 0, 0, 0, time.UTC)
+	recs := make([]*Record, len(lons))
+	for i, lon := range lons {
+		recs[i] = newRecord(ts0.Add(time.Duration(i)*time.Second), orb.Point{lon, 0})
+	}
+	return recs
+}
+
+func TestCleanOutliers(t *testing.T) {
+	testCases := []struct {
+		lons   []float64
+		expect []float64
+	}{
+		{[]float64{0, 0.00003, 0.01, 0.00006, 0.00009}, []float64{0, 0.00003, 0.00006, 0.00009}},
+		{[]float64{0.01, 0.005, 0, 0.00003, 0.00006}, []float64{0, 0.00003, 0.00006}},
+		{[]float64{0, 0.00003, 0.00006}, []float64{0, 0.00003, 0.00006}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			act := &Activity{Sport: "running", Records: cleanRecords(testCase.lons...)}
+			act.Distance = pathLength(act.Records)
+			dropped := (&Cleaner{}).Clean(act)
+			is.Equal(len(testCase.lons)-len(testCase.expect), dropped)
+			lons := make([]float64, len(act.Records))
+			for j, r := range act.Records {
+				lons[j] = r.Position.Lon()
+			}
+			is.Equal(testCase.expect, lons)
+			is.InDelta(pathLength(cleanRecords(testCase.expect...)), act.Distance, 1e-6)
+		})
+	}
+}
+
+func TestSelectDistanceAfterOutliers(t *testing.T) {
+	is := require.New(t)
+
+	act := &Activity{File: "a.gpx", Sport: "running", Records: cleanRecords(0, 0.00003, 0.01, 0.00006, 0.00009)}
+	act.Distance = pathLength(act.Records)
+	act.ElapsedTime = 4 * time.Second
+	act.MovingTime = act.ElapsedTime
+
+	acts, stats, _, err := Select([]*Activity{act}, &Selector{MaxDistance: 100}, &Cleaner{})
+	is.NoError(err)
+	is.Len(acts, 1)
+	is.InDelta(10, stats.SumDistance, 0.1)
+}
+
+func TestCleanKeepOutliers(t *testing.T) {
+	is := require.New(t)
+
+	act := &Activity{Records: cleanRecords(0, 0.01, 0)}
+	is.Zero((&Cleaner{KeepOutliers: true}).Clean(act))
+	is.Len(act.Records, 3)
+	act = &Activity{Records: cleanRecords(0, 0.01, 0)}
+	is.Zero((&Cleaner{MaxSpeed: 2000}).Clean(act))
+}
+
+func TestCleanMovingAverage(t *testing.T) {
+	is := require.New(t)
+
+	act := &Activity{Records: cleanRecords(0, 0.00003, 0, 0.00003)}
+	(&Cleaner{Smoother: "moving_average", Window: 3}).Clean(act)
+	is.InDelta(0.000015, act.Records[0].Position.Lon(), 1e-12)
+	is.InDelta(0.00001, act.Records[1].Position.Lon(), 1e-12)
+	is.InDelta(0.00002, act.Records[2].Position.Lon(), 1e-12)
+	is.InDelta(0.000015, act.Records[3].Position.Lon(), 1e-12)
+}
+
+func TestCleanKalman(t *testing.T) {
+	is := require.New(t)
+
+	act := &Activity{Records: cleanRecords(0, 0.00006, 0, 0.00006, 0)}
+	(&Cleaner{Smoother: "kalman"}).Clean(act)
+	is.Equal(0.0, act.Records[0].Position.Lon())
+	for _, r := range act.Records[1:] {
+		is.Greater(r.Position.Lon(), 0.0)
+		is.Less(r.Position.Lon(), 0.00006)
+	}
+}
+
+func TestCleanPrivacy(t *testing.T) {
+	testCases := []struct {
+		zones  []geo.Geometry
+		trim   float64
+		expect []float64
+	}{
+		{nil, 0, []float64{0, 0.0001, 0.0002, 0.0003, 0.0004, 0.0005, 0.0006}},
+		{[]geo.Geometry{geo.Circle{Radius: 15}}, 0, []float64{0.0002, 0.0003, 0.0004, 0.0005, 0.0006}},
+		{[]geo.Geometry{geo.Circle{Radius: 15}, geo.Circle{Origin: orb.Point{0.0006, 0}, Radius: 5}}, 0, []float64{0.0002, 0.0003, 0.0004, 0.0005}},
+		{nil, 20, []float64{0.0002, 0.0003, 0.0004}},
+		{[]geo.Geometry{geo.Circle{Radius: 15}}, 20, []float64{0.0004}},
+		{[]geo.Geometry{geo.Circle{Radius: 100}}, 0, []float64{}},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			act := &Activity{Distance: 1000, Records: cleanRecords(0, 0.0001, 0.0002, 0.0003, 0.0004, 0.0005, 0.0006)}
+			(&Cleaner{KeepOutliers: true, PrivacyZones: testCase.zones, PrivacyTrim: testCase.trim}).Clean(act)
+			lons := make([]float64, len(act.Records))
+			for j, r := range act.Records {
+				lons[j] = r.Position.Lon()
+			}
+			is.Equal(testCase.expect, lons)
+			is.InDelta(1000-11.119*float64(6-max(len(lons)-1, 0)), act.Distance, 0.01)
+		})
+	}
+}
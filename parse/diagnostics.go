@@ -0,0 +1,114 @@
+package parse
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/locale"
+)
+
+// Outcomes of a scanned file or of an activity within it.
+const (
+	Parsed      = "parsed"
+	Filtered    = "filtered"
+	Duplicate   = "duplicate"
+	Empty       = "empty"
+	Unsupported = "unsupported"
+	Corrupt     = "corrupt"
+)
+
+var Outcomes = []string{Parsed, Filtered, Duplicate, Empty, Unsupported, Corrupt}
+
+// Diagnostic describes what became of a scanned file, with one diagnostic per activity when the file contains any.
+// Reason is the selector criterion that filtered out the activity, or why the file isn't supported.
+type Diagnostic struct {
+	File        string     `json:"file"`
+	Outcome     string     `json:"outcome"`
+	Sport       string     `json:"sport,omitempty"`
+	Start       *time.Time `json:"start,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	DuplicateOf string     `json:"duplicate_of,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type Diagnostics []*Diagnostic
+
+// unsupportedError indicates a well-formed file that doesn't contain activities.
+type unsupportedError string
+
+func (e unsupportedError) Error() string {
+	return string(e)
+}
+
+func newDiagnostic(file string, act *Activity) *Diagnostic {
+	d := &Diagnostic{File: file, Outcome: Parsed, Sport: act.Sport}
+	if len(act.Records) > 0 {
+		ts := act.Records[0].Timestamp
+		d.Start = &ts
+	}
+	return d
+}
+
+func (d *Diagnostic) filter(reason string) {
+	d.Outcome = Filtered
+	d.Reason = reason
+}
+
+// Count returns the number of diagnostics with each outcome.
+func (d Diagnostics) Count() map[string]int {
+	counts := make(map[string]int)
+	for _, diag := range d {
+		counts[diag.Outcome]++
+	}
+	return counts
+}
+
+// Print summarizes the outcomes, breaking filtered activities down by criterion.
+func (d Diagnostics) Print(p *locale.Printer) {
+	counts := d.Count()
+	reasons := make(map[string]int)
+	for _, diag := range d {
+		if diag.Outcome == Filtered {
+			reasons[diag.Reason]++
+		}
+	}
+
+	for _, outcome := range Outcomes {
+		n := counts[outcome]
+		if n == 0 {
+			continue
+		}
+		if outcome == Filtered {
+			keys := make([]string, 0, len(reasons))
+			for k := range reasons {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool {
+				if reasons[keys[i]] != reasons[keys[j]] {
+					return reasons[keys[i]] > reasons[keys[j]]
+				}
+				return keys[i] < keys[j]
+			})
+			parts := make([]string, len(keys))
+			for i, k := range keys {
+				parts[i] = p.Sprintf("%s %d", k, reasons[k])
+			}
+			p.Field(outcome, "%d (%s)", n, strings.Join(parts, ", "))
+		} else {
+			p.Field(outcome, "%d", n)
+		}
+	}
+}
+
+// WriteJSON writes every diagnostic as an indented JSON array.
+func (d Diagnostics) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if d == nil {
+		d = Diagnostics{}
+	}
+	return enc.Encode(d)
+}
@@ -0,0 +1,84 @@
+package parse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	is := require.New(t)
+
+	track := func(sport string, start time.Time, points int) string {
+		sb := &strings.Builder{}
+		fmt.Fprintf(sb, `<trk><type>%s</type><trkseg>`, sport)
+		for i := 0; i < points; i++ {
+			fmt.Fprintf(sb, `<trkpt lat="-37.8" lon="%.5f"><time>%s</time></trkpt>`, 144.9+0.0001*float64(i), start.Add(time.Duration(i)*3*time.Second).Format(time.RFC3339))
+		}
+		sb.WriteString(`</trkseg></trk>`)
+		return sb.String()
+	}
+	ts0 := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	file := func(name, data string) *scan.File {
+		return &scan.File{Name: name, Ext: name[strings.LastIndex(name, "."):], Opener: func() (io.Reader, error) { return strings.NewReader(data), nil }}
+	}
+	files := []*scan.File{
+		file("a.gpx", `<gpx>`+track("running", ts0, 50)+track("cycling", ts0.Add(time.Hour), 50)+`</gpx>`),
+		file("b.gpx", `<gpx>`+track("running", ts0, 50)+`</gpx>`),
+		file("c.gpx", `<gpx>`+track("running", ts0.Add(2*time.Hour), 5)+`</gpx>`),
+		file("d.gpx", `<gpx><trk>`),
+		file("e.gpx", `<gpx></gpx>`),
+		file("f.txt", `hello`),
+	}
+
+	_, stats, diags, err := Parse(context.Background(), files, &Selector{Sports: []string{"running"}, MinDistance: 100}, &Cleaner{}, &Options{Jobs: 2})
+	is.NoError(err)
+	is.Equal(1, stats.CountActivities)
+	is.Len(diags, 7)
+
+	type row struct{ file, outcome, reason, dupOf string }
+	got := make([]row, len(diags))
+	for i, d := range diags {
+		got[i] = row{d.File, d.Outcome, d.Reason, d.DuplicateOf}
+	}
+	is.Equal(row{"a.gpx", Duplicate, "", "b.gpx"}, got[0])
+	is.Equal(row{"a.gpx", Filtered, "sport", ""}, got[1])
+	is.Equal(row{"b.gpx", Parsed, "", ""}, got[2])
+	is.Equal(row{"c.gpx", Filtered, "distance", ""}, got[3])
+	is.Equal(Corrupt, got[4].outcome)
+	is.NotEmpty(diags[4].Error)
+	is.Equal(row{"e.gpx", Empty, "", ""}, got[5])
+	is.Equal(row{"f.txt", Unsupported, `file extension ".txt"`, ""}, got[6])
+	is.Equal(ts0, *diags[0].Start)
+
+	counts := diags.Count()
+	is.Equal(1, counts[Parsed])
+	is.Equal(1, counts[Duplicate])
+	is.Equal(2, counts[Filtered])
+
+	buf := &bytes.Buffer{}
+	is.NoError(diags.WriteJSON(buf))
+	var rows []map[string]any
+	is.NoError(json.Unmarshal(buf.Bytes(), &rows))
+	is.Len(rows, 7)
+	is.Equal("sport", rows[1]["reason"])
+	is.NotContains(rows[5], "start")
+}
+
+func TestParseDiagnosticsNoMatches(t *testing.T) {
+	is := require.New(t)
+
+	files := []*scan.File{{Name: "a.gpx", Ext: ".gpx", Opener: func() (io.Reader, error) { return strings.NewReader("<gpx/>"), nil }}}
+	_, _, diags, err := Parse(context.Background(), files, &Selector{}, &Cleaner{}, nil)
+	is.EqualError(err, "no matching activities found")
+	is.Len(diags, 1)
+	is.Equal(Empty, diags[0].Outcome)
+}
@@ -0,0 +1,232 @@
+package parse
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// KeepPolicies choose which copy of a duplicated activity is kept: the longest, the one with the most sensor
+// readings, or the one recorded in a preferred file format.
+var KeepPolicies = []string{"longest", "sensors", "fit", "tcx", "gpx"}
+
+const (
+	// duplicateOverlap is the fraction of the shorter activity's time range that must overlap the other.
+	duplicateOverlap = 0.5
+	// duplicateTolerance is the greatest Hausdorff distance in meters between duplicate tracks.
+	duplicateTolerance = 50
+	// duplicateSamples caps the points compared from each track, bounding the quadratic cost of the comparison.
+	duplicateSamples = 200
+)
+
+// IsDuplicate reports whether two activities are recordings of the same outing, such as from a watch and a bike
+// computer or a re-export that dropped some points. Their time ranges must mostly overlap and their tracks must
+// follow each other closely throughout the overlap.
+func IsDuplicate(a, b *Activity) bool {
+	if len(a.Records) == 0 || len(b.Records) == 0 {
+		return false
+	}
+	a0, a1 := a.Records[0].Timestamp, a.Records[len(a.Records)-1].Timestamp
+	b0, b1 := b.Records[0].Timestamp, b.Records[len(b.Records)-1].Timestamp
+	start, end := a0, a1
+	if b0.After(start) {
+		start = b0
+	}
+	if b1.Before(end) {
+		end = b1
+	}
+	shorter := min(a1.Sub(a0), b1.Sub(b0))
+	if end.Before(start) || end.Sub(start) < time.Duration(duplicateOverlap*float64(shorter)) {
+		return false
+	}
+
+	pa, pb := pathBetween(a.Records, start, end), pathBetween(b.Records, start, end)
+	if len(pa) == 0 || len(pb) == 0 {
+		return false
+	}
+	return hausdorffWithin(pa, pb, duplicateTolerance)
+}
+
+// pathBetween returns the positions of at most duplicateSamples evenly spaced records within the time range,
+// bracketed by positions interpolated at either end so that sparse or simplified tracks are clipped exactly.
+func pathBetween(recs []*Record, start, end time.Time) orb.LineString {
+	i := sort.Search(len(recs), func(i int) bool { return !recs[i].Timestamp.Before(start) })
+	j := sort.Search(len(recs), func(j int) bool { return recs[j].Timestamp.After(end) })
+
+	var path orb.LineString
+	if i > 0 && i < len(recs) && recs[i].Timestamp.After(start) {
+		path = append(path, positionAt(recs[i-1], recs[i], start))
+	}
+	if n := j - i; n <= duplicateSamples {
+		for _, r := range recs[i:j] {
+			path = append(path, r.Position)
+		}
+	} else {
+		for k := range duplicateSamples {
+			path = append(path, recs[i+k*(n-1)/(duplicateSamples-1)].Position)
+		}
+	}
+	if j > 0 && j < len(recs) && recs[j-1].Timestamp.Before(end) {
+		path = append(path, positionAt(recs[j-1], recs[j], end))
+	}
+	return path
+}
+
+func positionAt(r0, r1 *Record, ts time.Time) orb.Point {
+	d := r1.Timestamp.Sub(r0.Timestamp)
+	if d <= 0 {
+		return r0.Position
+	}
+	f := float64(ts.Sub(r0.Timestamp)) / float64(d)
+	return orb.Point{r0.Position[0] + (r1.Position[0]-r0.Position[0])*f, r0.Position[1] + (r1.Position[1]-r0.Position[1])*f}
+}
+
+// hausdorffWithin reports whether the Hausdorff distance between the paths is at most tolerance meters,
+// measuring from the points of each path to the nearest segment of the other so that sparse or simplified
+// paths compare fairly with dense ones.
+func hausdorffWithin(a, b orb.LineString, tolerance float64) bool {
+	origin := a[0]
+	kx := geo.DistanceHaversine(origin, orb.Point{origin.Lon() + 1, origin.Lat()})
+	ky := geo.DistanceHaversine(origin, orb.Point{origin.Lon(), origin.Lat() + 1})
+	proj := func(path orb.LineString) orb.LineString {
+		res := make(orb.LineString, len(path))
+		for i, pt := range path {
+			res[i] = orb.Point{(pt.Lon() - origin.Lon()) * kx, (pt.Lat() - origin.Lat()) * ky}
+		}
+		return res
+	}
+	a, b = proj(a), proj(b)
+	return nearPath(a, b, tolerance) && nearPath(b, a, tolerance)
+}
+
+// nearPath reports whether every point lies within tolerance of the path.
+func nearPath(pts, path orb.LineString, tolerance float64) bool {
+	tol2 := tolerance * tolerance
+	for _, pt := range pts {
+		near := planar.DistanceSquared(pt, path[0]) <= tol2
+		for j := 1; j < len(path) && !near; j++ {
+			near = planar.DistanceFromSegmentSquared(path[j-1], path[j], pt) <= tol2
+		}
+		if !near {
+			return false
+		}
+	}
+	return true
+}
+
+// Duplicates finds the activities recorded more than once, mapping each duplicate to the copy kept in its place
+// under the policy. Activities are swept in order of start time so only those with overlapping time ranges are compared.
+func Duplicates(acts []*Activity, policy string) map[*Activity]*Activity {
+	byStart := make([]*Activity, 0, len(acts))
+	for _, act := range acts {
+		if len(act.Records) > 0 {
+			byStart = append(byStart, act)
+		}
+	}
+	sort.SliceStable(byStart, func(i, j int) bool {
+		return byStart[i].Records[0].Timestamp.Before(byStart[j].Records[0].Timestamp)
+	})
+	overlaps := make(map[*Activity][]*Activity)
+	for i, a := range byStart {
+		end := a.Records[len(a.Records)-1].Timestamp
+		for _, b := range byStart[i+1:] {
+			if b.Records[0].Timestamp.After(end) {
+				break
+			}
+			overlaps[a] = append(overlaps[a], b)
+			overlaps[b] = append(overlaps[b], a)
+		}
+	}
+
+	// reversed so that ties favor later activities
+	order := make([]*Activity, len(byStart))
+	for i, act := range byStart {
+		order[len(byStart)-1-i] = act
+	}
+	sort.SliceStable(order, func(i, j int) bool { return preferred(order[i], order[j], policy) })
+	rank := make(map[*Activity]int, len(order))
+	for i, act := range order {
+		rank[act] = i
+	}
+
+	dupes := make(map[*Activity]*Activity)
+	for _, act := range order {
+		var keep *Activity
+		for _, o := range overlaps[act] {
+			if _, dup := dupes[o]; dup || rank[o] > rank[act] {
+				continue
+			}
+			if (keep == nil || rank[o] < rank[keep]) && IsDuplicate(o, act) {
+				keep = o
+			}
+		}
+		if keep != nil {
+			dupes[act] = keep
+		}
+	}
+	return dupes
+}
+
+// dedupe drops activities that duplicate another, keeping the copy preferred by the policy and recording
+// the file each duplicate was dropped in favor of. The order of the kept activities is preserved.
+func dedupe(acts []*Activity, policy string) []*Activity {
+	dupes := Duplicates(acts, policy)
+	if len(dupes) == 0 {
+		return acts
+	}
+	uniq := acts[:0]
+	for _, act := range acts {
+		if keep, ok := dupes[act]; ok {
+			act.diag.Outcome = Duplicate
+			act.diag.DuplicateOf = keep.File
+		} else {
+			uniq = append(uniq, act)
+		}
+	}
+	return uniq
+}
+
+// preferred reports whether a should be kept over b under the policy, falling back to the longest.
+func preferred(a, b *Activity, policy string) bool {
+	switch policy {
+	case "sensors":
+		if na, nb := sensorReadings(a), sensorReadings(b); na != nb {
+			return na > nb
+		}
+	case "fit", "tcx", "gpx":
+		if fa, fb := fileFormat(a.File) == policy, fileFormat(b.File) == policy; fa != fb {
+			return fa
+		}
+	}
+	if da, db := a.Records[len(a.Records)-1].Timestamp.Sub(a.Records[0].Timestamp), b.Records[len(b.Records)-1].Timestamp.Sub(b.Records[0].Timestamp); da != db {
+		return da > db
+	}
+	return a.Distance > b.Distance
+}
+
+func sensorReadings(act *Activity) int {
+	n := 0
+	for _, r := range act.Records {
+		for _, v := range []float64{r.Elevation, r.HeartRate, r.Cadence, r.Power, r.Speed} {
+			if !math.IsNaN(v) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// fileFormat returns the lowercase extension of the file name without the dot, ignoring any gzip compression.
+func fileFormat(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == ".gz" {
+		ext = strings.ToLower(filepath.Ext(name[:len(name)-3]))
+	}
+	return strings.TrimPrefix(ext, ".")
+}
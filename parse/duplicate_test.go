@@ -0,0 +1,115 @@
+package parse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+var dupTS = time.Date(2024, 3, 9, 7, 0, 0, 0, time.UTC)
+
+// dupActivity records a track heading east from lon 144.9 at about 9m per second, from the given offsets.
+func dupActivity(file string, from, to int, lat float64) *Activity {
+	act := &Activity{File: file}
+	for i := from; i < to; i++ {
+		act.Records = append(act.Records, newRecord(dupTS.Add(time.Duration(i)*time.Second), orb.Point{144.9 + 0.0001*float64(i), lat}))
+	}
+	act.Distance = float64(to-from) * 9
+	act.diag = newDiagnostic(file, act)
+	return act
+}
+
+// sparse keeps only the ends of the track, as a simplified straight track would.
+func sparse(act *Activity) *Activity {
+	act.Records = []*Record{act.Records[0], act.Records[len(act.Records)-1]}
+	return act
+}
+
+func TestIsDuplicate(t *testing.T) {
+	testCases := []struct {
+		a, b   *Activity
+		expect bool
+	}{
+		{dupActivity("a", 0, 600, -37.8), dupActivity("b", 0, 600, -37.8), true},
+		{dupActivity("a", 0, 600, -37.8), dupActivity("b", 1, 600, -37.8), true},
+		{dupActivity("a", 0, 600, -37.8), dupActivity("b", 5, 590, -37.8002), true},
+		{dupActivity("a", 0, 600, -37.8), dupActivity("b", 200, 500, -37.8), true},
+		{dupActivity("a", 0, 600, -37.8), sparse(dupActivity("b", 1, 600, -37.8)), true},
+		{dupActivity("a", 200, 500, -37.8), sparse(dupActivity("b", 0, 600, -37.8)), true},
+		{dupActivity("a", 0, 600, -37.8), dupActivity("b", 0, 600, -37.801), false},
+		{dupActivity("a", 0, 600, -37.8), sparse(dupActivity("b", 0, 600, -37.801)), false},
+		{dupActivity("a", 0, 600, -37.8), dupActivity("b", 500, 1100, -37.8), false},
+		{dupActivity("a", 0, 600, -37.8), dupActivity("b", 700, 900, -37.8), false},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+			is.Equal(testCase.expect, IsDuplicate(testCase.a, testCase.b))
+			is.Equal(testCase.expect, IsDuplicate(testCase.b, testCase.a))
+		})
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	testCases := []struct {
+		policy string
+		keep   string
+	}{
+		{"", "watch.fit"},
+		{"longest", "watch.fit"},
+		{"sensors", "strava.gpx"},
+		{"gpx", "strava.gpx"},
+		{"tcx", "watch.fit"},
+	}
+
+	for i, testCase := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			watch := dupActivity("watch.fit", 0, 600, -37.8)
+			strava := dupActivity("strava.gpx", 1, 600, -37.8)
+			for _, r := range strava.Records {
+				r.HeartRate = 140
+			}
+			other := dupActivity("other.tcx", 0, 600, -37.7)
+
+			acts := dedupe([]*Activity{watch, strava, other}, testCase.policy)
+			is.Len(acts, 2)
+			is.Equal(testCase.keep, acts[0].File)
+			is.Equal("other.tcx", acts[1].File)
+
+			dropped := watch
+			if testCase.keep == watch.File {
+				dropped = strava
+			}
+			is.Equal(Duplicate, dropped.diag.Outcome)
+			is.Equal(testCase.keep, dropped.diag.DuplicateOf)
+			is.Equal(Parsed, other.diag.Outcome)
+		})
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	is := require.New(t)
+
+	later := dupActivity("later.gpx", 1000, 1600, -37.8)
+	short := dupActivity("short.gpx", 100, 400, -37.8)
+	long := dupActivity("long.gpx", 0, 600, -37.8)
+	mid := dupActivity("mid.gpx", 50, 550, -37.8)
+	apart := dupActivity("apart.gpx", 0, 600, -37.7)
+
+	dupes := Duplicates([]*Activity{later, short, long, mid, apart}, "longest")
+	is.Equal(map[*Activity]*Activity{short: long, mid: long}, dupes)
+	is.Empty(Duplicates([]*Activity{later, long, apart, {File: "empty.gpx"}}, "longest"))
+}
+
+func TestFileFormat(t *testing.T) {
+	is := require.New(t)
+	is.Equal("fit", fileFormat("export.zip/activities/123.FIT.gz"))
+	is.Equal("gpx", fileFormat("run.gpx"))
+	is.Equal("", fileFormat("README"))
+}
@@ -1,8 +1,8 @@
 package parse
 
 import (
-	"errors"
 	"io"
+	"math"
 	"strings"
 	"time"
 
@@ -10,19 +10,15 @@ import (
 	"github.com/tormoder/fit"
 )
 
-func parseFIT(r io.Reader, selector *Selector) ([]*Activity, error) {
+func parseFIT(r io.Reader) ([]*Activity, error) {
 	f, err := fit.Decode(r)
 	if err != nil {
-		var ferr fit.FormatError
-		if errors.As(err, &ferr) {
-			return nil, nil
-		}
 		return nil, err
 	}
 
 	if a, err := f.Activity(); err != nil {
 		if strings.HasPrefix(err.Error(), "fit file type is ") {
-			return nil, nil
+			return nil, unsupportedError(err.Error())
 		}
 		return nil, err
 	} else if len(a.Records) == 0 {
@@ -32,31 +28,67 @@ func parseFIT(r io.Reader, selector *Selector) ([]*Activity, error) {
 			Sport:    a.Sessions[0].Sport.String(),
 			Distance: a.Sessions[0].GetTotalDistanceScaled(),
 		}
-		r0, r1 := a.Records[0], a.Records[len(a.Records)-1]
-		dur := time.Duration(a.Activity.GetTotalTimerTimeScaled()) * time.Second
-		if dur == 0 {
-			dur = r1.Timestamp.Sub(r0.Timestamp)
-		}
-		if !selector.Sport(act.Sport) ||
-			!selector.Timestamp(r0.Timestamp, r1.Timestamp) ||
-			!selector.Duration(dur) ||
-			!selector.Distance(act.Distance) ||
-			!selector.Pace(dur, act.Distance) {
-			return nil, nil
-		}
-
 		act.Records = make([]*Record, 0, len(a.Records))
 		for _, rec := range a.Records {
 			if !rec.PositionLat.Invalid() && !rec.PositionLong.Invalid() {
-				act.Records = append(act.Records, &Record{
-					Timestamp: rec.Timestamp,
-					Position:  orb.Point{rec.PositionLong.Degrees(), rec.PositionLat.Degrees()},
-				})
+				r := newRecord(rec.Timestamp, orb.Point{rec.PositionLong.Degrees(), rec.PositionLat.Degrees()})
+				if r.Elevation = rec.GetEnhancedAltitudeScaled(); math.IsNaN(r.Elevation) {
+					r.Elevation = rec.GetAltitudeScaled()
+				}
+				if r.Speed = rec.GetEnhancedSpeedScaled(); math.IsNaN(r.Speed) {
+					r.Speed = rec.GetSpeedScaled()
+				}
+				if rec.HeartRate != 0xff {
+					r.HeartRate = float64(rec.HeartRate)
+				}
+				if rec.Cadence != 0xff {
+					r.Cadence = float64(rec.Cadence)
+				}
+				if rec.Power != 0xffff {
+					r.Power = float64(rec.Power)
+				}
+				act.Records = append(act.Records, r)
 			}
 		}
 		if len(act.Records) == 0 {
 			return nil, nil
 		}
+
+		act.detectPauses(fitPauses(a))
+		if a.Activity != nil {
+			act.Duration = time.Duration(a.Activity.GetTotalTimerTimeScaled()) * time.Second
+		}
+		if act.Duration == 0 {
+			act.Duration = act.ElapsedTime
+		}
 		return []*Activity{act}, nil
 	}
 }
+
+// fitPauses collects periods when the timer was stopped along with gaps between laps.
+func fitPauses(a *fit.ActivityFile) []Pause {
+	var pauses []Pause
+	var stopped time.Time
+	for _, e := range a.Events {
+		if e.Event != fit.EventTimer {
+			continue
+		}
+		switch e.EventType {
+		case fit.EventTypeStop, fit.EventTypeStopAll, fit.EventTypeStopDisableAll:
+			if stopped.IsZero() {
+				stopped = e.Timestamp
+			}
+		case fit.EventTypeStart:
+			if !stopped.IsZero() {
+				pauses = append(pauses, Pause{stopped, e.Timestamp})
+				stopped = time.Time{}
+			}
+		}
+	}
+	for i := 1; i < len(a.Laps); i++ {
+		if end, start := a.Laps[i-1].Timestamp, a.Laps[i].StartTime; start.After(end) {
+			pauses = append(pauses, Pause{end, start})
+		}
+	}
+	return pauses
+}
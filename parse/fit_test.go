@@ -3,6 +3,7 @@ package parse
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
 	"testing"
 	"time"
 
@@ -20,7 +21,35 @@ func TestFITShortDistance(t *testing.T) {
 	a.Sessions = append(a.Sessions, &fit.SessionMsg{TotalDistance: 1})
 	a.Records = append(a.Records, &fit.RecordMsg{Timestamp: time.Now()}, &fit.RecordMsg{Timestamp: time.Now().Add(time.Second)})
 	is.NoError(fit.Encode(w, f, binary.BigEndian))
-	acts, err := parseFIT(w, &Selector{})
+	acts, err := parseFIT(w)
 	is.NoError(err)
 	is.Len(acts, 1)
 }
+
+func TestFITSensorData(t *testing.T) {
+	is := require.New(t)
+
+	w := &bytes.Buffer{}
+	f, err := fit.NewFile(fit.FileTypeActivity, fit.NewHeader(fit.V20, false))
+	is.NoError(err)
+	a, _ := f.Activity()
+	a.Sessions = append(a.Sessions, &fit.SessionMsg{TotalDistance: 1})
+	r0, r1 := fit.NewRecordMsg(), fit.NewRecordMsg()
+	r0.Timestamp, r1.Timestamp = time.Now(), time.Now().Add(time.Second)
+	r0.PositionLat, r0.PositionLong = fit.NewLatitudeDegrees(1), fit.NewLongitudeDegrees(2)
+	r1.PositionLat, r1.PositionLong = fit.NewLatitudeDegrees(1), fit.NewLongitudeDegrees(2)
+	r0.HeartRate = 150
+	r0.Power = 300
+	r0.Altitude = 2600
+	a.Records = append(a.Records, r0, r1)
+	is.NoError(fit.Encode(w, f, binary.BigEndian))
+	acts, err := parseFIT(w)
+	is.NoError(err)
+	is.Len(acts, 1)
+	is.Len(acts[0].Records, 2)
+	is.Equal(150.0, acts[0].Records[0].HeartRate)
+	is.Equal(300.0, acts[0].Records[0].Power)
+	is.Equal(20.0, acts[0].Records[0].Elevation)
+	is.True(math.IsNaN(acts[0].Records[0].Cadence))
+	is.True(math.IsNaN(acts[0].Records[1].HeartRate))
+}
@@ -2,6 +2,7 @@ package parse
 
 import (
 	"io"
+	"strconv"
 	"strings"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
@@ -45,7 +46,7 @@ var stravaTypeCodes = map[string]string{
 	"53": "VirtualRunning",
 }
 
-func parseGPX(r io.Reader, selector *Selector) ([]*Activity, error) {
+func parseGPX(r io.Reader) ([]*Activity, error) {
 	buf, err := io.ReadAll(r)
 	if err != nil {
 		return nil, err
@@ -65,47 +66,68 @@ func parseGPX(r io.Reader, selector *Selector) ([]*Activity, error) {
 				sport = s
 			}
 		}
-		if len(t.Segments) == 0 || !selector.Sport(sport) {
+		if len(t.Segments) == 0 {
 			continue
 		}
 
 		act := &Activity{
+			Name:    t.Name,
 			Sport:   sport,
 			Records: make([]*Record, 0, len(t.Segments[0].Points)),
 		}
 
-		var p0, p1 gpx.GPXPoint
+		var pauses []Pause
 		for _, s := range t.Segments {
 			if len(s.Points) == 0 {
 				continue
 			}
+			if len(act.Records) > 0 {
+				pauses = append(pauses, Pause{act.Records[len(act.Records)-1].Timestamp, s.Points[0].Timestamp})
+			}
 
 			for i, p := range s.Points {
-				if len(act.Records) == 0 {
-					p0 = p
+				r := newRecord(p.Timestamp, orb.Point{p.Longitude, p.Latitude})
+				if p.Elevation.NotNull() {
+					r.Elevation = p.Elevation.Value()
 				}
-				p1 = p
-				act.Records = append(act.Records, &Record{
-					Timestamp: p.Timestamp,
-					Position:  orb.Point{p.Longitude, p.Latitude},
-				})
+				parseGPXExtensions(p.Extensions.Nodes, r)
 				if i > 0 {
-					act.Distance += geo.DistanceHaversine(act.Records[i-1].Position, act.Records[i].Position)
+					act.Distance += geo.DistanceHaversine(act.Records[len(act.Records)-1].Position, r.Position)
 				}
+				act.Records = append(act.Records, r)
 			}
 		}
 
-		dur := p1.Timestamp.Sub(p0.Timestamp)
-		if len(act.Records) == 0 ||
-			!selector.Timestamp(p0.Timestamp, p1.Timestamp) ||
-			!selector.Duration(dur) ||
-			!selector.Distance(act.Distance) ||
-			!selector.Pace(dur, act.Distance) {
+		if len(act.Records) == 0 {
 			continue
 		}
-
+		act.detectPauses(pauses)
+		act.Duration = act.ElapsedTime
 		acts = append(acts, act)
 	}
 
 	return acts, nil
 }
+
+func parseGPXExtensions(nodes []gpx.ExtensionNode, r *Record) {
+	for _, n := range nodes {
+		if len(n.Nodes) > 0 {
+			parseGPXExtensions(n.Nodes, r)
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(n.Data), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(n.XMLName.Local) {
+		case "hr", "heartrate":
+			r.HeartRate = v
+		case "cad", "cadence":
+			r.Cadence = v
+		case "power", "watts":
+			r.Power = v
+		case "speed":
+			r.Speed = v
+		}
+	}
+}
@@ -2,7 +2,9 @@ package parse
 
 import (
 	"bytes"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -34,7 +36,69 @@ func TestGPXStravaTypeCodes(t *testing.T) {
 		      </trkpt>
 		    </trkseg>
 		  </trk>
-		</gpx>`), &Selector{Sports: []string{"running"}})
+		</gpx>`))
+	is.NoError(err)
+	is.Len(acts, 2)
+	is.Equal("Cycling", acts[0].Sport)
+	is.Equal("Running", acts[1].Sport)
+}
+
+func TestGPXSensorData(t *testing.T) {
+	is := require.New(t)
+
+	acts, err := parseGPX(bytes.NewBufferString(`
+		<gpx xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+		  <trk>
+		    <trkseg>
+		      <trkpt lat="7.61969" lon="22.30989">
+		        <ele>12.5</ele>
+		        <time>2022-02-13T00:07:06Z</time>
+		        <extensions>
+		          <gpxtpx:TrackPointExtension>
+		            <gpxtpx:hr>140</gpxtpx:hr>
+		            <gpxtpx:cad>85</gpxtpx:cad>
+		          </gpxtpx:TrackPointExtension>
+		          <power>250</power>
+		        </extensions>
+		      </trkpt>
+		      <trkpt lat="7.61968" lon="22.30988">
+		        <time>2022-02-13T00:07:07Z</time>
+		      </trkpt>
+		    </trkseg>
+		  </trk>
+		</gpx>`))
+	is.NoError(err)
+	is.Len(acts, 1)
+	r0, r1 := acts[0].Records[0], acts[0].Records[1]
+	is.Equal(12.5, r0.Elevation)
+	is.Equal(140.0, r0.HeartRate)
+	is.Equal(85.0, r0.Cadence)
+	is.Equal(250.0, r0.Power)
+	is.True(math.IsNaN(r0.Speed))
+	is.True(math.IsNaN(r1.Elevation))
+	is.True(math.IsNaN(r1.HeartRate))
+}
+
+func TestGPXSegmentPause(t *testing.T) {
+	is := require.New(t)
+
+	acts, err := parseGPX(bytes.NewBufferString(`
+		<gpx>
+		  <trk>
+		    <trkseg>
+		      <trkpt lat="0" lon="0"><time>2022-02-13T00:00:00Z</time></trkpt>
+		      <trkpt lat="0" lon="0.001"><time>2022-02-13T00:00:10Z</time></trkpt>
+		    </trkseg>
+		    <trkseg>
+		      <trkpt lat="0" lon="0.002"><time>2022-02-13T00:00:20Z</time></trkpt>
+		      <trkpt lat="0" lon="0.003"><time>2022-02-13T00:00:30Z</time></trkpt>
+		    </trkseg>
+		  </trk>
+		</gpx>`))
 	is.NoError(err)
 	is.Len(acts, 1)
+	is.Len(acts[0].Pauses, 1)
+	is.Equal(30*time.Second, acts[0].ElapsedTime)
+	is.Equal(20*time.Second, acts[0].MovingTime)
+	is.InDelta(222.4, acts[0].Distance, 0.1)
 }
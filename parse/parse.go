@@ -1,63 +1,133 @@
 package parse
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math"
-	"os"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/progress"
 	"github.com/NathanBaulch/rainbow-roads/scan"
 	"github.com/paulmach/orb"
 	"golang.org/x/exp/slices"
 	"golang.org/x/text/message"
 )
 
-func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error) {
-	wg := sync.WaitGroup{}
-	wg.Add(len(files))
+// Options control how files are read.
+type Options struct {
+	// Jobs is the number of files read concurrently, defaulting to the number of CPUs.
+	Jobs int
+	// Progress is notified of the number of files read so far.
+	Progress progress.Reporter
+	// Cache, when set, stores decoded activities so repeat runs skip decoding unchanged files.
+	Cache *Cache
+}
+
+// Parse reads the activities from the given files that satisfy the selector. The diagnostics describe
+// the outcome of every file and are returned even when no activities match.
+func Parse(ctx context.Context, files []*scan.File, selector *Selector, cleaner *Cleaner, opts *Options) ([]*Activity, *Stats, Diagnostics, error) {
+	found, diags, err := Decode(ctx, files, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	activities, stats, err := selectActivities(found, selector, cleaner)
+	return activities, stats, diags, err
+}
+
+// Decode reads every activity from the given files without selecting or cleaning them,
+// along with a diagnostic for every file and activity.
+func Decode(ctx context.Context, files []*scan.File, opts *Options) ([]*Activity, Diagnostics, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
 	res := make([]struct {
-		acts []*Activity
-		err  error
+		acts  []*Activity
+		diags Diagnostics
 	}, len(files))
-	for i := range files {
+	next := make(chan int)
+	var done atomic.Int64
+	wg := sync.WaitGroup{}
+	wg.Add(min(jobs, len(files)))
+	for range min(jobs, len(files)) {
 		go func() {
 			defer wg.Done()
-			var parser func(io.Reader, *Selector) ([]*Activity, error)
-			switch files[i].Ext {
-			case ".fit":
-				parser = parseFIT
-			case ".gpx":
-				parser = parseGPX
-			case ".tcx":
-				parser = parseTCX
-			default:
-				return
-			}
-			if r, err := files[i].Opener(); err != nil {
-				res[i].err = err
-			} else {
-				res[i].acts, res[i].err = parser(r, selector)
+			for i := range next {
+				res[i].acts, res[i].diags = decodeFile(files[i], opts.Cache)
+				progress.Report(opts.Progress, progress.Parse, done.Add(1), int64(len(files)))
 			}
 		}()
 	}
+	for i := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- i
+	}
+	close(next)
 	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 
 	activities := make([]*Activity, 0, len(files))
+	diags := make(Diagnostics, 0, len(files))
 	for _, r := range res {
-		if r.err != nil {
-			fmt.Fprintln(os.Stderr, "WARN:", r.err)
-		} else {
-			activities = append(activities, r.acts...)
-		}
+		activities = append(activities, r.acts...)
+		diags = append(diags, r.diags...)
 	}
-	if len(activities) == 0 {
-		return nil, nil, errors.New("no matching activities found")
+	return activities, diags, nil
+}
+
+// Select filters and cleans previously decoded activities, such as those loaded from an index,
+// returning those that satisfy the selector along with a diagnostic for every activity.
+// The given activities are left unmodified, so they may be shared by concurrent callers.
+func Select(acts []*Activity, selector *Selector, cleaner *Cleaner) ([]*Activity, *Stats, Diagnostics, error) {
+	found := make([]*Activity, len(acts))
+	diags := make(Diagnostics, len(acts))
+	for i, act := range acts {
+		found[i] = act.clone()
+		diags[i] = found[i].diag
+	}
+	activities, stats, err := selectActivities(found, selector, cleaner)
+	return activities, stats, diags, err
+}
+
+func selectActivities(found []*Activity, selector *Selector, cleaner *Cleaner) ([]*Activity, *Stats, error) {
+	activities := make([]*Activity, 0, len(found))
+	for _, act := range found {
+		if act.Duration == 0 {
+			act.Duration = act.ElapsedTime
+		}
+		if len(act.Records) == 0 {
+			act.diag.Outcome = Empty
+			continue
+		}
+		if reason := selector.reject(act); reason != "" {
+			act.diag.filter(reason)
+			continue
+		}
+		if act.dropped = cleaner.Clean(act); len(act.Records) > 0 {
+			act.detectPauses(act.Pauses)
+		}
+		// distance and pace are checked after cleaning, once distance implied by outliers is discounted
+		if reason := selector.rejectDistance(act); reason != "" {
+			act.diag.filter(reason)
+			continue
+		}
+		activities = append(activities, act)
 	}
 
 	stats := &Stats{
@@ -69,37 +139,56 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 	}
 	var startExtent, endExtent orb.Bound
 
-	uniq := make(map[time.Time]bool)
+	passed := make([]bool, len(selector.PassesThrough))
 
-	for i := len(activities) - 1; i >= 0; i-- {
-		act := activities[i]
-		include := selector.PassesThrough == nil
-		exclude := len(act.Records) == 0
+	matched := activities[:0]
+	for _, act := range activities {
+		include := len(selector.PassesThrough) == 0
+		clear(passed)
+		reason := ""
+		if len(act.Records) == 0 {
+			act.diag.Outcome = Empty
+			continue
+		}
 		for j, r := range act.Records {
 			if !selector.Bounded(r.Position) {
-				exclude = true
+				reason = "bounded_by"
 				break
 			}
 			if j == 0 && !selector.Starts(r.Position) {
-				exclude = true
+				reason = "starts_near"
 				break
 			}
 			if j == len(act.Records)-1 && !selector.Ends(r.Position) {
-				exclude = true
+				reason = "ends_near"
 				break
 			}
-			if !include && selector.Passes(r.Position) {
+			if !include && selector.Passes(r.Position, passed) {
 				include = true
 			}
 		}
-		if exclude || !include || uniq[act.Records[0].Timestamp] {
-			j := len(activities) - 1
-			activities[i] = activities[j]
-			activities = activities[:j]
+		if reason == "" && !include {
+			reason = "passes_through"
+		}
+		if reason == "" {
+			if act.ElevationGain == 0 {
+				act.ElevationGain = ElevationGain(act.Records)
+			}
+			if ok, err := selector.Where.Match(act); err != nil {
+				return nil, nil, fmt.Errorf("where expression error: %w", err)
+			} else if !ok {
+				reason = "where"
+			}
+		}
+		if reason != "" {
+			act.diag.filter(reason)
 			continue
 		}
-		uniq[act.Records[0].Timestamp] = true
+		matched = append(matched, act)
+	}
+	activities = dedupe(matched, cleaner.KeepDuplicate)
 
+	for _, act := range activities {
 		if act.Sport == "" {
 			stats.SportCounts["unknown"]++
 		} else {
@@ -125,7 +214,7 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 		if act.Distance > stats.MaxDistance {
 			stats.MaxDistance = act.Distance
 		}
-		pace := time.Duration(float64(dur) / act.Distance)
+		pace := time.Duration(float64(act.MovingTime) / act.Distance)
 		if pace < stats.MinPace {
 			stats.MinPace = pace
 		}
@@ -134,10 +223,18 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 		}
 
 		stats.CountRecords += len(act.Records)
+		stats.CountDropped += act.dropped
 		stats.SumDuration += dur
+		stats.SumMovingTime += act.MovingTime
 		stats.SumDistance += act.Distance
+		stats.SumElevationGain += act.ElevationGain
 
 		for _, r := range act.Records {
+			stats.Elevation.Add(r.Elevation)
+			stats.HeartRate.Add(r.HeartRate)
+			stats.Cadence.Add(r.Cadence)
+			stats.Power.Add(r.Power)
+			stats.Speed.Add(r.Speed)
 			if stats.Extent.IsZero() {
 				stats.Extent = orb.Bound{Min: r.Position, Max: r.Position}
 			} else {
@@ -175,13 +272,83 @@ func Parse(files []*scan.File, selector *Selector) ([]*Activity, *Stats, error)
 	return activities, stats, nil
 }
 
+var decoders = map[string]func(io.Reader) ([]*Activity, error){
+	".fit": parseFIT,
+	".gpx": parseGPX,
+	".tcx": parseTCX,
+}
+
+// decodeFile returns the activities in the file along with a diagnostic for the file or each of its activities.
+func decodeFile(file *scan.File, cache *Cache) ([]*Activity, Diagnostics) {
+	decode, ok := decoders[file.Ext]
+	if !ok {
+		return nil, Diagnostics{{File: file.Name, Outcome: Unsupported, Reason: fmt.Sprintf("file extension %q", file.Ext)}}
+	}
+
+	r, err := file.Opener()
+	if err != nil {
+		return nil, Diagnostics{{File: file.Name, Outcome: Corrupt, Error: err.Error()}}
+	}
+	var acts []*Activity
+	if cache != nil {
+		acts, err = cache.decode(r, file.Ext, decode)
+	} else {
+		acts, err = decode(r)
+	}
+	if err != nil {
+		var uerr unsupportedError
+		if errors.As(err, &uerr) {
+			return nil, Diagnostics{{File: file.Name, Outcome: Unsupported, Reason: uerr.Error()}}
+		}
+		return nil, Diagnostics{{File: file.Name, Outcome: Corrupt, Error: err.Error()}}
+	}
+	if len(acts) == 0 {
+		return nil, Diagnostics{{File: file.Name, Outcome: Empty}}
+	}
+
+	diags := make(Diagnostics, len(acts))
+	for i, act := range acts {
+		act.File = file.Name
+		act.diag = newDiagnostic(file.Name, act)
+		diags[i] = act.diag
+	}
+	return acts, diags
+}
+
 type Selector struct {
-	Sports                                         []string
-	After, Before                                  time.Time
-	MinDuration, MaxDuration                       time.Duration
-	MinDistance, MaxDistance                       float64
-	MinPace, MaxPace                               time.Duration
-	BoundedBy, StartsNear, EndsNear, PassesThrough geo.Geometry
+	Sports                          []string
+	After, Before                   time.Time
+	MinDuration, MaxDuration        time.Duration
+	MinDistance, MaxDistance        float64
+	MinPace, MaxPace                time.Duration
+	BoundedBy, StartsNear, EndsNear geo.Geometry
+	PassesThrough                   []geo.Geometry
+	Where                           Where
+}
+
+// reject returns the first criterion that the activity fails, or an empty string if it's selected.
+func (s *Selector) reject(act *Activity) string {
+	switch {
+	case !s.Sport(act.Sport):
+		return "sport"
+	case !s.Timestamp(act.Records[0].Timestamp, act.Records[len(act.Records)-1].Timestamp):
+		return "date"
+	case !s.Duration(act.Duration):
+		return "duration"
+	default:
+		return ""
+	}
+}
+
+func (s *Selector) rejectDistance(act *Activity) string {
+	switch {
+	case !s.Distance(act.Distance):
+		return "distance"
+	case !s.Pace(act.MovingTime, act.Distance):
+		return "pace"
+	default:
+		return ""
+	}
 }
 
 func (s *Selector) Sport(sport string) bool {
@@ -223,49 +390,174 @@ func (s *Selector) Ends(pt orb.Point) bool {
 	return s.EndsNear == nil || s.EndsNear.Contains(pt)
 }
 
-func (s *Selector) Passes(pt orb.Point) bool {
-	return s.PassesThrough == nil || s.PassesThrough.Contains(pt)
+// Passes marks each region that contains the point, returning true once every region has been passed through.
+func (s *Selector) Passes(pt orb.Point, passed []bool) bool {
+	all := true
+	for i, g := range s.PassesThrough {
+		if !passed[i] {
+			if passed[i] = g.Contains(pt); !passed[i] {
+				all = false
+			}
+		}
+	}
+	return all
 }
 
 type Activity struct {
-	Sport    string
-	Distance float64
-	Records  []*Record
+	File          string
+	Name          string
+	Sport         string
+	Distance      float64
+	ElevationGain float64
+	ElapsedTime   time.Duration
+	MovingTime    time.Duration
+	Duration      time.Duration // recorded duration used for selection, timer based in some formats
+	Pauses        []Pause
+	Records       []*Record
+	dropped       int
+	diag          *Diagnostic
 }
 
+// clone returns a deep copy of the activity with its own records and diagnostic.
+func (act *Activity) clone() *Activity {
+	c := *act
+	c.Pauses = slices.Clone(act.Pauses)
+	c.Records = make([]*Record, len(act.Records))
+	for i, r := range act.Records {
+		rc := *r
+		c.Records[i] = &rc
+	}
+	if act.diag != nil {
+		d := *act.diag
+		c.diag = &d
+	} else {
+		c.diag = newDiagnostic(act.File, act)
+	}
+	return &c
+}
+
+// Record sensor channels are NaN when missing.
 type Record struct {
 	Timestamp time.Time
 	Position  orb.Point
+	Elevation float64
+	HeartRate float64
+	Cadence   float64
+	Power     float64
+	Speed     float64
 	X, Y      int
 	Percent   float64
 }
 
+func newRecord(ts time.Time, pos orb.Point) *Record {
+	return &Record{
+		Timestamp: ts,
+		Position:  pos,
+		Elevation: math.NaN(),
+		HeartRate: math.NaN(),
+		Cadence:   math.NaN(),
+		Power:     math.NaN(),
+		Speed:     math.NaN(),
+	}
+}
+
+// elevationThreshold is the minimum climb counted towards elevation gain, suppressing sensor noise.
+const elevationThreshold = 2
+
+// ElevationGain sums the climbs in the given records, ignoring those below a noise threshold.
+func ElevationGain(records []*Record) float64 {
+	gain, ref := 0.0, math.NaN()
+	for _, r := range records {
+		if math.IsNaN(r.Elevation) {
+			continue
+		}
+		if math.IsNaN(ref) || r.Elevation < ref {
+			ref = r.Elevation
+		} else if r.Elevation-ref >= elevationThreshold {
+			gain += r.Elevation - ref
+			ref = r.Elevation
+		}
+	}
+	return gain
+}
+
 type Stats struct {
 	CountActivities, CountRecords         int
+	CountDropped                          int
 	SportCounts                           map[string]int
 	After, Before                         time.Time
 	MinDuration, MaxDuration, SumDuration time.Duration
+	SumMovingTime                         time.Duration
 	MinDistance, MaxDistance, SumDistance float64
 	MinPace, MaxPace                      time.Duration
+	SumElevationGain                      float64
+	Elevation, HeartRate, Cadence, Power  SensorStats
+	Speed                                 SensorStats
 	BoundedBy, StartsNear, EndsNear       geo.Circle
 	Extent                                orb.Bound
 }
 
-func (s *Stats) Print(p *message.Printer) {
+type SensorStats struct {
+	Count         int
+	Min, Max, Sum float64
+}
+
+func (s *SensorStats) Add(v float64) {
+	if math.IsNaN(v) {
+		return
+	}
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Count++
+	s.Sum += v
+}
+
+func (s *SensorStats) Avg() float64 {
+	if s.Count == 0 {
+		return math.NaN()
+	}
+	return s.Sum / float64(s.Count)
+}
+
+func (s *Stats) Print(p *locale.Printer) {
 	avgDur := s.SumDuration / time.Duration(s.CountActivities)
 	avgDist := s.SumDistance / float64(s.CountActivities)
-	avgPace := s.SumDuration / time.Duration(s.SumDistance)
-
-	p.Printf("activities:    %d\n", s.CountActivities)
-	p.Printf("records:       %d\n", s.CountRecords)
-	p.Printf("sports:        %s\n", sprintSportStats(p, s.SportCounts))
-	p.Printf("period:        %s\n", sprintPeriod(p, s.After, s.Before))
-	p.Printf("duration:      %s to %s, average %s, total %s\n", sprintDuration(p, s.MinDuration), sprintDuration(p, s.MaxDuration), sprintDuration(p, avgDur), sprintDuration(p, s.SumDuration))
-	p.Printf("distance:      %s to %s, average %s, total %s\n", sprintDistance(p, s.MinDistance), sprintDistance(p, s.MaxDistance), sprintDistance(p, avgDist), sprintDistance(p, s.SumDistance))
-	p.Printf("pace:          %s to %s, average %s\n", sprintPace(p, s.MinPace), sprintPace(p, s.MaxPace), sprintPace(p, avgPace))
-	p.Printf("bounds:        %s\n", s.BoundedBy)
-	p.Printf("starts within: %s\n", s.StartsNear)
-	p.Printf("ends within:   %s\n", s.EndsNear)
+	avgPace := s.SumMovingTime / time.Duration(s.SumDistance)
+
+	p.Field("activities", "%d", s.CountActivities)
+	if s.CountDropped > 0 {
+		p.Field("records", "%d, dropped %d outliers", s.CountRecords, s.CountDropped)
+	} else {
+		p.Field("records", "%d", s.CountRecords)
+	}
+	p.Field("sports", "%s", sprintSportStats(p.Printer, s.SportCounts))
+	p.Field("period", "%s", sprintPeriod(p.Printer, s.After, s.Before))
+	p.Field("duration", "%s to %s, average %s, total %s", p.Duration(s.MinDuration), p.Duration(s.MaxDuration), p.Duration(avgDur), p.Duration(s.SumDuration))
+	p.Field("moving time", "average %s, total %s", p.Duration(s.SumMovingTime/time.Duration(s.CountActivities)), p.Duration(s.SumMovingTime))
+	p.Field("distance", "%s to %s, average %s, total %s", p.Distance(s.MinDistance), p.Distance(s.MaxDistance), p.Distance(avgDist), p.Distance(s.SumDistance))
+	p.Field("pace", "%s to %s, average %s", p.Pace(s.MinPace), p.Pace(s.MaxPace), p.Pace(avgPace))
+	if s.Elevation.Count > 0 {
+		p.Field("elevation", "%s to %s, gain %s", p.Elevation(s.Elevation.Min), p.Elevation(s.Elevation.Max), p.Elevation(s.SumElevationGain))
+	}
+	if s.HeartRate.Count > 0 {
+		p.Field("heart rate", "average %.0fbpm, max %.0fbpm", s.HeartRate.Avg(), s.HeartRate.Max)
+	}
+	if s.Cadence.Count > 0 {
+		p.Field("cadence", "average %.0frpm, max %.0frpm", s.Cadence.Avg(), s.Cadence.Max)
+	}
+	if s.Power.Count > 0 {
+		p.Field("power", "average %.0fW, max %.0fW", s.Power.Avg(), s.Power.Max)
+	}
+	if s.Speed.Count > 0 {
+		p.Field("speed", "average %s, max %s", p.Speed(s.Speed.Avg()), p.Speed(s.Speed.Max))
+	}
+	p.Field("bounds", "%s", s.BoundedBy)
+	p.Field("starts within", "%s", s.StartsNear)
+	p.Field("ends within", "%s", s.EndsNear)
 }
 
 func sprintSportStats(p *message.Printer, stats map[string]int) string {
@@ -314,17 +606,5 @@ func sprintPeriod(p *message.Printer, minDate, maxDate time.Time) string {
 	default:
 		num, unit = d.Seconds(), "seconds"
 	}
-	return p.Sprintf("%.1f %s (%s to %s)", num, unit, minDate.Format("2006-01-02"), maxDate.Format("2006-01-02"))
-}
-
-func sprintDuration(p *message.Printer, dur time.Duration) string {
-	return p.Sprintf("%s", dur.Truncate(time.Second))
-}
-
-func sprintDistance(p *message.Printer, dist float64) string {
-	return p.Sprintf("%.1fkm", dist/1000)
-}
-
-func sprintPace(p *message.Printer, pace time.Duration) string {
-	return p.Sprintf("%s/km", (pace * 1000).Truncate(time.Second))
+	return p.Sprintf("%.1f %s (%s to %s)", num, p.Sprintf(unit), minDate.Format("2006-01-02"), maxDate.Format("2006-01-02"))
 }
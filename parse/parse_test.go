@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"context"
+	"io"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElevationGain(t *testing.T) {
+	is := require.New(t)
+
+	recs := make([]*Record, 0)
+	for _, e := range []float64{10, 11, 10, 13, math.NaN(), 12, 20, 19, 20.5} {
+		recs = append(recs, &Record{Elevation: e})
+	}
+	is.Equal(11.0, ElevationGain(recs))
+}
+
+func TestSensorStats(t *testing.T) {
+	is := require.New(t)
+
+	s := SensorStats{}
+	is.True(math.IsNaN(s.Avg()))
+	for _, v := range []float64{3, math.NaN(), 1, 2} {
+		s.Add(v)
+	}
+	is.Equal(3, s.Count)
+	is.Equal(1.0, s.Min)
+	is.Equal(3.0, s.Max)
+	is.Equal(2.0, s.Avg())
+}
+
+func TestSelectorPasses(t *testing.T) {
+	is := require.New(t)
+
+	s := Selector{PassesThrough: []geo.Geometry{
+		geo.Circle{Origin: orb.Point{0, 0}, Radius: 1000},
+		geo.Circle{Origin: orb.Point{1, 1}, Radius: 1000},
+	}}
+	passed := make([]bool, len(s.PassesThrough))
+	is.False(s.Passes(orb.Point{0, 0}, passed))
+	is.False(s.Passes(orb.Point{0.5, 0.5}, passed))
+	is.True(s.Passes(orb.Point{1, 1}, passed))
+	is.Equal([]bool{true, true}, passed)
+
+	clear(passed)
+	is.False(s.Passes(orb.Point{1, 1}, passed))
+	is.True((&Selector{}).Passes(orb.Point{}, nil))
+}
+
+func TestParseCanceled(t *testing.T) {
+	is := require.New(t)
+
+	opened := atomic.Int32{}
+	files := make([]*scan.File, 100)
+	for i := range files {
+		files[i] = &scan.File{Name: "a.gpx", Ext: ".gpx", Opener: func() (io.Reader, error) {
+			opened.Add(1)
+			return strings.NewReader("<gpx/>"), nil
+		}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, err := Parse(ctx, files, &Selector{}, &Cleaner{}, &Options{Jobs: 2})
+	is.ErrorIs(err, context.Canceled)
+	is.Zero(opened.Load())
+}
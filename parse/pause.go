@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+)
+
+const (
+	// stationaryRadius is how far records can wander while still considered stationary.
+	stationaryRadius = 10
+	// stationarySpeed is the average speed below which a gap in recording is considered a pause.
+	stationarySpeed = 0.5
+	// minPause is the shortest stationary period considered a pause.
+	minPause = 30 * time.Second
+)
+
+type Pause struct {
+	Start, End time.Time
+}
+
+// detectPauses combines explicit pauses signalled by the source file with stationary periods found in the records,
+// then derives elapsed and moving time.
+func (a *Activity) detectPauses(explicit []Pause) {
+	pauses := append([]Pause(nil), explicit...)
+	recs := a.Records
+
+	anchor := 0
+	for i := 1; i <= len(recs); i++ {
+		if i < len(recs) {
+			r0, r1 := recs[i-1], recs[i]
+			if dt := r1.Timestamp.Sub(r0.Timestamp); dt >= minPause && geo.DistanceHaversine(r0.Position, r1.Position) < stationarySpeed*dt.Seconds() {
+				pauses = append(pauses, Pause{r0.Timestamp, r1.Timestamp})
+			}
+			if geo.DistanceHaversine(recs[anchor].Position, r1.Position) <= stationaryRadius {
+				continue
+			}
+		}
+		if t0, t1 := recs[anchor].Timestamp, recs[i-1].Timestamp; t1.Sub(t0) >= minPause {
+			pauses = append(pauses, Pause{t0, t1})
+		}
+		anchor = i
+	}
+
+	a.Pauses = mergePauses(pauses, recs[0].Timestamp, recs[len(recs)-1].Timestamp)
+	a.ElapsedTime = recs[len(recs)-1].Timestamp.Sub(recs[0].Timestamp)
+	a.MovingTime = a.ElapsedTime
+	for _, p := range a.Pauses {
+		a.MovingTime -= p.End.Sub(p.Start)
+	}
+}
+
+func mergePauses(pauses []Pause, from, to time.Time) []Pause {
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i].Start.Before(pauses[j].Start) })
+	merged := make([]Pause, 0, len(pauses))
+	for _, p := range pauses {
+		if p.Start.Before(from) {
+			p.Start = from
+		}
+		if p.End.After(to) {
+			p.End = to
+		}
+		if !p.End.After(p.Start) {
+			continue
+		}
+		if i := len(merged) - 1; i >= 0 && !p.Start.After(merged[i].End) {
+			if p.End.After(merged[i].End) {
+				merged[i].End = p.End
+			}
+		} else {
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+// MovingOffset returns the moving time elapsed between the start of the activity and the given timestamp.
+func (a *Activity) MovingOffset(ts time.Time) time.Duration {
+	d := ts.Sub(a.Records[0].Timestamp)
+	for _, p := range a.Pauses {
+		if !p.Start.Before(ts) {
+			break
+		}
+		if p.End.After(ts) {
+			d -= ts.Sub(p.Start)
+		} else {
+			d -= p.End.Sub(p.Start)
+		}
+	}
+	return d
+}
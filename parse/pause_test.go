@@ -0,0 +1,37 @@
+package parse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectPauses(t *testing.T) {
+	is := require.New(t)
+
+	ts0 := time.Date(2022, 2, 13, 0, 0, 0, 0, time.UTC)
+	at := func(secs int) time.Time { return ts0.Add(time.Duration(secs) * time.Second) }
+	act := &Activity{}
+	for _, r := range []struct {
+		secs int
+		lon  float64
+	}{
+		{0, 0}, {10, 0.001}, {20, 0.002},
+		{30, 0.002}, {40, 0.002}, {50, 0.002}, {60, 0.002},
+		{70, 0.003}, {80, 0.004},
+		{200, 0.0041},
+		{210, 0.005}, {220, 0.006}, {230, 0.007},
+	} {
+		act.Records = append(act.Records, newRecord(at(r.secs), orb.Point{r.lon, 0}))
+	}
+	act.detectPauses([]Pause{{at(215), at(225)}, {at(-10), at(5)}})
+
+	is.Equal([]Pause{{at(0), at(5)}, {at(20), at(60)}, {at(80), at(200)}, {at(215), at(225)}}, act.Pauses)
+	is.Equal(230*time.Second, act.ElapsedTime)
+	is.Equal(55*time.Second, act.MovingTime)
+	is.Equal(15*time.Second, act.MovingOffset(at(40)))
+	is.Equal(25*time.Second, act.MovingOffset(at(70)))
+	is.Equal(55*time.Second, act.MovingOffset(at(230)))
+}
@@ -1,65 +1,99 @@
 package parse
 
 import (
+	"bytes"
+	"encoding/xml"
 	"io"
+	"math"
 
 	"github.com/llehouerou/go-tcx"
 	"github.com/paulmach/orb"
 )
 
-func parseTCX(r io.Reader, selector *Selector) ([]*Activity, error) {
-	f, err := tcx.Parse(r)
+// tcxAltitudes mirrors the trackpoint layout of tcx.Tcx, distinguishing missing altitudes from those at sea level.
+type tcxAltitudes struct {
+	Activities []struct {
+		Laps []struct {
+			Track []struct {
+				Altitude *float64 `xml:"AltitudeMeters"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+func parseTCX(r io.Reader) ([]*Activity, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	f, err := tcx.Parse(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
+	alts := &tcxAltitudes{}
+	if err := xml.Unmarshal(data, alts); err != nil {
+		return nil, err
+	}
 
 	acts := make([]*Activity, 0, len(f.Activities))
 
-	for _, a := range f.Activities {
-		if len(a.Laps) == 0 || !selector.Sport(a.Sport) {
+	for i, a := range f.Activities {
+		if len(a.Laps) == 0 {
 			continue
 		}
 
 		act := &Activity{
-			Sport:   a.Sport,
-			Records: make([]*Record, 0, len(a.Laps[0].Track)),
+			Sport:    a.Sport,
+			Distance: a.TotalDistance(),
+			Records:  make([]*Record, 0, len(a.Laps[0].Track)),
 		}
 
-		var t0, t1 tcx.Trackpoint
-		for _, l := range a.Laps {
+		var pauses []Pause
+		for j, l := range a.Laps {
 			if len(l.Track) == 0 {
 				continue
 			}
 
-			for _, t := range l.Track {
+			first := true
+			for k, t := range l.Track {
 				if t.LatitudeInDegrees == 0 || t.LongitudeInDegrees == 0 {
 					continue
 				}
-				if len(act.Records) == 0 {
-					t0 = t
+				if first && len(act.Records) > 0 {
+					pauses = append(pauses, Pause{act.Records[len(act.Records)-1].Timestamp, t.Time})
+				}
+				first = false
+				r := newRecord(t.Time, orb.Point{t.LongitudeInDegrees, t.LatitudeInDegrees})
+				if alts.Activities[i].Laps[j].Track[k].Altitude != nil {
+					r.Elevation = t.AltitudeInMeters
 				}
-				t1 = t
-				act.Records = append(act.Records, &Record{
-					Timestamp: t.Time,
-					Position:  orb.Point{t.LongitudeInDegrees, t.LatitudeInDegrees},
-				})
+				r.HeartRate = nonZero(float64(t.HeartRateInBpm))
+				r.Cadence = nonZero(float64(t.Cadence))
+				if math.IsNaN(r.Cadence) {
+					r.Cadence = nonZero(float64(t.Extensions.TrackPoint.RunCadence))
+				}
+				r.Power = nonZero(float64(t.Extensions.TrackPoint.Watts))
+				r.Speed = nonZero(t.Extensions.TrackPoint.Speed)
+				act.Records = append(act.Records, r)
 			}
 		}
 
-		dur := a.TotalDuration()
-		if dur == 0 {
-			dur = t1.Time.Sub(t0.Time)
-		}
-		if len(act.Records) == 0 ||
-			!selector.Timestamp(t0.Time, t1.Time) ||
-			!selector.Duration(dur) ||
-			!selector.Distance(a.TotalDistance()) ||
-			!selector.Pace(dur, act.Distance) {
+		if len(act.Records) == 0 {
 			continue
 		}
-
+		act.detectPauses(pauses)
+		if act.Duration = a.TotalDuration(); act.Duration == 0 {
+			act.Duration = act.ElapsedTime
+		}
 		acts = append(acts, act)
 	}
 
 	return acts, nil
 }
+
+func nonZero(v float64) float64 {
+	if v == 0 {
+		return math.NaN()
+	}
+	return v
+}
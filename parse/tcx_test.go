@@ -2,6 +2,7 @@ package parse
 
 import (
 	"bytes"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -23,7 +24,37 @@ func TestTCXNoPosition(t *testing.T) {
 		      </Lap>
 		    </Activity>
 		  </Activities>
-		</TrainingCenterDatabase>`), &Selector{})
+		</TrainingCenterDatabase>`))
 	is.NoError(err)
 	is.Empty(acts)
 }
+
+func TestTCXSeaLevel(t *testing.T) {
+	is := require.New(t)
+
+	acts, err := parseTCX(bytes.NewBufferString(`
+		<TrainingCenterDatabase>
+		  <Activities>
+		    <Activity Sport="Running">
+		      <Lap>
+		        <Track>
+		          <Trackpoint>
+		            <Time>2024-01-01T06:00:00Z</Time>
+		            <Position><LatitudeDegrees>-37.8</LatitudeDegrees><LongitudeDegrees>144.9</LongitudeDegrees></Position>
+		            <AltitudeMeters>0</AltitudeMeters>
+		          </Trackpoint>
+		          <Trackpoint>
+		            <Time>2024-01-01T06:00:05Z</Time>
+		            <Position><LatitudeDegrees>-37.8</LatitudeDegrees><LongitudeDegrees>144.9001</LongitudeDegrees></Position>
+		          </Trackpoint>
+		        </Track>
+		      </Lap>
+		    </Activity>
+		  </Activities>
+		</TrainingCenterDatabase>`))
+	is.NoError(err)
+	is.Len(acts, 1)
+	is.Len(acts[0].Records, 2)
+	is.Zero(acts[0].Records[0].Elevation)
+	is.True(math.IsNaN(acts[0].Records[1].Elevation))
+}
@@ -0,0 +1,83 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Where is a compiled expression that activities must satisfy, the zero value matches everything.
+type Where struct {
+	source  string
+	program *vm.Program
+}
+
+// WhereVariables lists the names available to where expressions.
+var WhereVariables = []string{"sport", "distance", "duration", "pace", "start", "weekday", "hour", "elevation_gain", "file", "name"}
+
+func CompileWhere(source string) (Where, error) {
+	env := map[string]any{
+		"sport":          "",
+		"distance":       0.0,
+		"duration":       0.0,
+		"pace":           0.0,
+		"start":          time.Time{},
+		"weekday":        "",
+		"hour":           0,
+		"elevation_gain": 0.0,
+		"file":           "",
+		"name":           "",
+	}
+	program, err := expr.Compile(source,
+		expr.Env(env),
+		expr.AsBool(),
+		expr.Function("date", func(params ...any) (any, error) {
+			str := params[0].(string)
+			for _, layout := range []string{"2006-01-02", time.RFC3339} {
+				if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+					return t, nil
+				}
+			}
+			return nil, fmt.Errorf("date %q not recognized", str)
+		}, new(func(string) time.Time)),
+	)
+	if err != nil {
+		return Where{}, err
+	}
+	return Where{source: source, program: program}, nil
+}
+
+func (w Where) String() string {
+	return w.source
+}
+
+func (w Where) Match(act *Activity) (bool, error) {
+	if w.program == nil {
+		return true, nil
+	}
+
+	start := act.Records[0].Timestamp.Local()
+	pace := 0.0
+	if act.Distance > 0 {
+		pace = act.MovingTime.Seconds() / (act.Distance / 1000)
+	}
+	res, err := expr.Run(w.program, map[string]any{
+		"sport":          strings.ToLower(act.Sport),
+		"distance":       act.Distance,
+		"duration":       act.ElapsedTime.Seconds(),
+		"pace":           pace,
+		"start":          start,
+		"weekday":        start.Weekday().String()[:3],
+		"hour":           start.Hour(),
+		"elevation_gain": act.ElevationGain,
+		"file":           act.File,
+		"name":           act.Name,
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.(bool), nil
+}
@@ -0,0 +1,67 @@
+package parse
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhere(t *testing.T) {
+	act := &Activity{
+		File:          "exports/morning.gpx",
+		Name:          "Morning Run",
+		Sport:         "Running",
+		Distance:      21500,
+		ElevationGain: 120,
+		ElapsedTime:   2 * time.Hour,
+		MovingTime:    100 * time.Minute,
+		Records:       []*Record{{Timestamp: time.Date(2023, 1, 7, 7, 30, 0, 0, time.Local)}},
+	}
+
+	testCases := []struct {
+		where  string
+		expect bool
+	}{
+		{"sport == 'running' and weekday in ['Sat','Sun'] and distance > 21000", true},
+		{"weekday == 'Mon'", false},
+		{"duration == 7200 and hour == 7", true},
+		{"pace > 279 and pace < 280", true},
+		{"elevation_gain >= 100", true},
+		{"file endsWith '.gpx' and name contains 'Run'", true},
+		{"start > date('2023-01-07') and start < date('2023-01-08')", true},
+		{"start.Year() == 2022", false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			w, err := CompileWhere(tc.where)
+			is.NoError(err)
+			is.Equal(tc.where, w.String())
+			ok, err := w.Match(act)
+			is.NoError(err)
+			is.Equal(tc.expect, ok)
+		})
+	}
+}
+
+func TestWhereErrors(t *testing.T) {
+	is := require.New(t)
+
+	_, err := CompileWhere("speed > 3")
+	is.ErrorContains(err, "unknown name speed")
+	_, err = CompileWhere("distance")
+	is.ErrorContains(err, "expected bool")
+
+	w, err := CompileWhere("start > date('yesterday')")
+	is.NoError(err)
+	_, err = w.Match(&Activity{Records: []*Record{{}}})
+	is.ErrorContains(err, `date "yesterday" not recognized`)
+
+	ok, err := Where{}.Match(&Activity{})
+	is.NoError(err)
+	is.True(ok)
+}
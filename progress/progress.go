@@ -0,0 +1,115 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stages reported by the scan, parse, render and encode steps.
+const (
+	Scan   = "scan"
+	Parse  = "parse"
+	Fetch  = "fetch"
+	Render = "render"
+	Encode = "encode"
+)
+
+// Reporter is notified as each stage of work advances.
+// A total of zero means the total is not yet known, and a stage is complete once done equals total.
+// Implementations must be safe for concurrent use.
+type Reporter interface {
+	Progress(stage string, done, total int64)
+}
+
+// ReporterFunc adapts an ordinary function to a Reporter.
+type ReporterFunc func(stage string, done, total int64)
+
+func (f ReporterFunc) Progress(stage string, done, total int64) {
+	f(stage, done, total)
+}
+
+// Report forwards progress to r, ignoring nil reporters.
+func Report(r Reporter, stage string, done, total int64) {
+	if r != nil {
+		r.Progress(stage, done, total)
+	}
+}
+
+// Writer counts the bytes written through it and reports them against the given stage.
+type Writer struct {
+	io.Writer
+	Reporter Reporter
+	Stage    string
+	n        int64
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	Report(w.Reporter, w.Stage, w.n, 0)
+	return n, err
+}
+
+// Done reports the stage as complete.
+func (w *Writer) Done() {
+	Report(w.Reporter, w.Stage, w.n, w.n)
+}
+
+// Bar draws a single-line progress bar for the current stage, intended for a terminal.
+type Bar struct {
+	w        io.Writer
+	mu       sync.Mutex
+	interval time.Duration
+	stage    string
+	last     time.Time
+	drawn    bool
+}
+
+const barWidth = 30
+
+func NewBar(w io.Writer) *Bar {
+	return &Bar{w: w, interval: 100 * time.Millisecond}
+}
+
+func (b *Bar) Progress(stage string, done, total int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete := total > 0 && done >= total
+	now := time.Now()
+	if stage == b.stage && !complete && now.Sub(b.last) < b.interval {
+		return
+	}
+	b.stage = stage
+	b.last = now
+
+	line := fmt.Sprintf("%-7s", stage)
+	if total > 0 {
+		n := int(barWidth * min(done, total) / total)
+		line += fmt.Sprintf(" [%s%s] %d/%d", strings.Repeat("=", n), strings.Repeat(" ", barWidth-n), done, total)
+	} else {
+		line += fmt.Sprintf(" %d", done)
+	}
+	_, _ = fmt.Fprint(b.w, "\r\x1b[K"+line)
+	b.drawn = true
+	if complete {
+		b.clear()
+	}
+}
+
+// Clear erases the bar so other output can follow on a clean line.
+func (b *Bar) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+}
+
+func (b *Bar) clear() {
+	if b.drawn {
+		_, _ = fmt.Fprint(b.w, "\r\x1b[K")
+		b.drawn = false
+	}
+}
@@ -0,0 +1,51 @@
+package progress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBar(t *testing.T) {
+	is := require.New(t)
+
+	buf := &bytes.Buffer{}
+	bar := NewBar(buf)
+	bar.Progress(Parse, 5, 10)
+	is.Equal("\r\x1b[Kparse   [===============               ] 5/10", buf.String())
+
+	buf.Reset()
+	bar.Progress(Parse, 6, 10)
+	is.Empty(buf.String(), "throttled")
+
+	buf.Reset()
+	bar.Progress(Scan, 42, 0)
+	is.Equal("\r\x1b[Kscan    42", buf.String())
+
+	buf.Reset()
+	bar.Progress(Scan, 42, 42)
+	is.True(strings.HasSuffix(buf.String(), "42/42\r\x1b[K"), "cleared when complete")
+
+	buf.Reset()
+	bar.Clear()
+	is.Empty(buf.String())
+}
+
+func TestWriter(t *testing.T) {
+	is := require.New(t)
+
+	var got [][2]int64
+	w := &Writer{Writer: io.Discard, Stage: Encode, Reporter: ReporterFunc(func(stage string, done, total int64) {
+		is.Equal(Encode, stage)
+		got = append(got, [2]int64{done, total})
+	})}
+	_, _ = w.Write([]byte("abc"))
+	_, _ = w.Write([]byte("de"))
+	w.Done()
+	is.Equal([][2]int64{{3, 0}, {5, 0}, {5, 5}}, got)
+
+	is.NotPanics(func() { Report(nil, Encode, 1, 1) })
+}
@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -11,16 +12,26 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/progress"
 )
 
 type File struct {
-	Ext    string
-	Opener func() (io.Reader, error)
+	Name    string
+	Ext     string
+	Size    int64
+	ModTime time.Time
+	Opener  func() (io.Reader, error)
 }
 
-func Scan(paths []string) ([]*File, error) {
+// Scan finds the files at the given paths, reporting the number found so far to rep.
+func Scan(ctx context.Context, paths []string, rep progress.Reporter) ([]*File, error) {
 	var files []*File
-	err := walkPaths(paths, func(fsys fs.FS, path string) error {
+	err := walkPaths(paths, func(fsys fs.FS, path, name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		ext := strings.ToLower(filepath.Ext(path))
 		opener := func() (io.Reader, error) { return fsys.Open(path) }
 		if ext == ".gz" {
@@ -33,13 +44,25 @@ func Scan(paths []string) ([]*File, error) {
 				}
 			}
 		}
-		files = append(files, &File{ext, opener})
+		file := &File{Name: name, Ext: ext, Opener: opener}
+		if fi, err := fs.Stat(fsys, path); err == nil {
+			file.Size = fi.Size()
+			file.ModTime = fi.ModTime()
+		}
+		files = append(files, file)
+		progress.Report(rep, progress.Scan, int64(len(files)), 0)
 		return nil
 	})
-	return files, err
+	if err != nil {
+		return nil, err
+	}
+	progress.Report(rep, progress.Scan, int64(len(files)), int64(len(files)))
+	return files, nil
 }
 
-func walkPaths(paths []string, fn func(fsys fs.FS, path string) error) error {
+// walkPaths calls fn for every file found, along with a display name that includes the directory and any
+// enclosing ZIP files.
+func walkPaths(paths []string, fn func(fsys fs.FS, path, name string) error) error {
 	for _, path := range paths {
 		paths := []string{path}
 		if strings.ContainsAny(path, "*?[") {
@@ -54,6 +77,7 @@ func walkPaths(paths []string, fn func(fsys fs.FS, path string) error) error {
 
 		for _, path := range paths {
 			dir, name := filepath.Split(path)
+			prefix := dir
 			if dir == "" {
 				dir = "."
 			}
@@ -65,10 +89,10 @@ func walkPaths(paths []string, fn func(fsys fs.FS, path string) error) error {
 				}
 				return err
 			} else if fi.IsDir() {
-				if err := walkDir(fsys, name, fn); err != nil {
+				if err := walkDir(fsys, name, prefix, fn); err != nil {
 					return err
 				}
-			} else if err := walkFile(fsys, name, fn); err != nil {
+			} else if err := walkFile(fsys, name, prefix, fn); err != nil {
 				return err
 			}
 		}
@@ -77,17 +101,17 @@ func walkPaths(paths []string, fn func(fsys fs.FS, path string) error) error {
 	return nil
 }
 
-func walkDir(fsys fs.FS, path string, fn func(fsys fs.FS, path string) error) error {
+func walkDir(fsys fs.FS, path, prefix string, fn func(fsys fs.FS, path, name string) error) error {
 	return fs.WalkDir(fsys, path, func(path string, d fs.DirEntry, err error) error {
 		if err != nil || d.IsDir() {
 			return err
 		} else {
-			return walkFile(fsys, path, fn)
+			return walkFile(fsys, path, prefix, fn)
 		}
 	})
 }
 
-func walkFile(fsys fs.FS, path string, fn func(fsys fs.FS, path string) error) error {
+func walkFile(fsys fs.FS, path, prefix string, fn func(fsys fs.FS, path, name string) error) error {
 	if strings.EqualFold(filepath.Ext(path), ".zip") {
 		if f, err := fsys.Open(path); err != nil {
 			return err
@@ -105,10 +129,10 @@ func walkFile(fsys fs.FS, path string, fn func(fsys fs.FS, path string) error) e
 			if fsys, err := zip.NewReader(r, s.Size()); err != nil {
 				return err
 			} else {
-				return walkDir(fsys, ".", fn)
+				return walkDir(fsys, ".", prefix+path+"/", fn)
 			}
 		}
 	} else {
-		return fn(fsys, path)
+		return fn(fsys, path, filepath.FromSlash(prefix+path))
 	}
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/stats"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/language"
+)
+
+var (
+	statsOpts = &stats.Options{
+		Units:  locale.Metric,
+		Locale: language.English,
+	}
+	statsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize activities by sport or period",
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			if statsOpts.Index != "" && len(args) > 0 {
+				return flagError("index", statsOpts.Index, "not supported with input paths")
+			}
+			if statsOpts.Jobs < 0 {
+				return flagError("jobs", statsOpts.Jobs, "must not be negative")
+			}
+			if !slices.Contains(stats.GroupBys, statsOpts.GroupBy) {
+				return flagError("group_by", statsOpts.GroupBy, "not supported")
+			}
+			if !slices.Contains(stats.Formats, statsOpts.Format) {
+				return flagError("format", statsOpts.Format, "not supported")
+			}
+			return validateCleaner(&statsOpts.Cleaner)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statsOpts.Input = args
+			statsOpts.Cache = activityCache()
+			return withProgress(func(rep progress.Reporter) error {
+				statsOpts.Progress = rep
+				return stats.Run(cmd.Context(), statsOpts)
+			})
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	general := &pflag.FlagSet{}
+	general.StringVarP(&statsOpts.Output, "output", "o", "", "optional path of the generated file, defaults to standard output")
+	general.StringVarP(&statsOpts.Format, "format", "f", "table", "output format, supports "+strings.Join(stats.Formats, ", "))
+	general.StringVarP(&statsOpts.GroupBy, "group_by", "g", "sport", "grouping of activity totals, supports "+strings.Join(stats.GroupBys, ", "))
+	general.Var((*UnitsFlag)(&statsOpts.Units), "units", "system of units used to print measurements, supports "+strings.Join(locale.UnitSystems, ", ")+"; JSON and CSV output is always metric")
+	general.Var((*LocaleFlag)(&statsOpts.Locale), "locale", "language used to print numbers and labels, eg de, en-US")
+	general.StringVar(&statsOpts.Index, "index", "", "optional path of an activity index to query instead of scanning input files")
+	general.StringVar(&statsOpts.Report, "report", "", "optional path of a JSON report listing the outcome of every scanned file")
+	general.IntVar(&statsOpts.Jobs, "jobs", 0, "number of files parsed concurrently, defaults to the number of CPUs")
+	general.BoolVar(&noCache, "no_cache", false, "disable the cache of parsed activities kept in the user cache directory")
+	general.VisitAll(statsCmd.Flags().AddFlag)
+
+	filters := filterFlagSet(&statsOpts.Selector)
+	filters.VisitAll(statsCmd.Flags().AddFlag)
+
+	cleaning := cleanFlagSet(&statsOpts.Cleaner)
+	cleaning.VisitAll(statsCmd.Flags().AddFlag)
+
+	statsCmd.SetUsageFunc(func(*cobra.Command) error {
+		fmt.Fprintln(statsCmd.OutOrStderr())
+		fmt.Fprintln(statsCmd.OutOrStderr(), "Usage:")
+		fmt.Fprintln(statsCmd.OutOrStderr(), " ", statsCmd.UseLine(), "[input]")
+		fmt.Fprintln(statsCmd.OutOrStderr())
+		fmt.Fprintln(statsCmd.OutOrStderr(), "General flags:")
+		fmt.Fprintln(statsCmd.OutOrStderr(), general.FlagUsages())
+		fmt.Fprintln(statsCmd.OutOrStderr(), "Filtering flags:")
+		fmt.Fprintln(statsCmd.OutOrStderr(), filters.FlagUsages())
+		fmt.Fprintln(statsCmd.OutOrStderr(), "Cleaning flags:")
+		fmt.Fprint(statsCmd.OutOrStderr(), cleaning.FlagUsages())
+		return nil
+	})
+}
@@ -0,0 +1,247 @@
+package stats
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/conv"
+	"github.com/NathanBaulch/rainbow-roads/index"
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/output"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"golang.org/x/text/language"
+)
+
+var (
+	GroupBys = []string{"sport", "week", "month", "year", "weekday"}
+	Formats  = []string{"table", "json", "csv"}
+)
+
+type Options struct {
+	Input    []string
+	Index    string
+	Output   string
+	Report   string
+	GroupBy  string
+	Format   string
+	Units    locale.Units
+	Locale   language.Tag
+	Jobs     int
+	Progress progress.Reporter
+	Cache    *parse.Cache
+	Selector parse.Selector
+	Cleaner  parse.Cleaner
+}
+
+// Group aggregates activities, with distance and elevation in meters, durations in seconds
+// and pace in seconds per kilometer.
+type Group struct {
+	Key           string  `json:"group"`
+	Activities    int     `json:"activities"`
+	Distance      float64 `json:"distance"`
+	Duration      float64 `json:"duration"`
+	MovingTime    float64 `json:"moving_time"`
+	Pace          float64 `json:"pace"`
+	ElevationGain float64 `json:"elevation_gain"`
+}
+
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
+	if len(o.Input) == 0 {
+		o.Input = []string{"."}
+	}
+	if o.GroupBy == "" {
+		o.GroupBy = "sport"
+	}
+	if o.Format == "" {
+		o.Format = "table"
+	}
+
+	var (
+		activities []*parse.Activity
+		diags      parse.Diagnostics
+		err        error
+	)
+	if o.Index != "" {
+		var ix *index.Index
+		if ix, err = index.Open(o.Index); err != nil {
+			return err
+		}
+		activities, _, diags, err = parse.Select(ix.Activities(), &o.Selector, &o.Cleaner)
+	} else {
+		var files []*scan.File
+		if files, err = scan.Scan(ctx, o.Input, o.Progress); err != nil {
+			return err
+		}
+		activities, _, diags, err = parse.Parse(ctx, files, &o.Selector, &o.Cleaner, &parse.Options{Jobs: o.Jobs, Progress: o.Progress, Cache: o.Cache})
+	}
+	if o.Report != "" && diags != nil {
+		if err := output.Create(o.Report, diags.WriteJSON); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	groups, total := aggregate(activities, o.GroupBy)
+
+	if o.Output == "" {
+		return write(os.Stdout, &o, groups, total)
+	}
+
+	return output.Create(o.Output, func(w io.Writer) error { return write(w, &o, groups, total) })
+}
+
+func write(w io.Writer, o *Options, groups []*Group, total *Group) error {
+	switch o.Format {
+	case "table":
+		return writeTable(w, locale.NewPrinter(o.Locale, o.Units), o.GroupBy, groups, total)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			GroupBy string   `json:"group_by"`
+			Groups  []*Group `json:"groups"`
+			Total   *Group   `json:"total"`
+		}{o.GroupBy, groups, total})
+	case "csv":
+		return writeCSV(w, o.GroupBy, groups)
+	default:
+		return fmt.Errorf("format %q not supported", o.Format)
+	}
+}
+
+// aggregate totals the activities by the given grouping, ordered chronologically or by descending count for sports.
+func aggregate(acts []*parse.Activity, groupBy string) ([]*Group, *Group) {
+	byKey := make(map[string]*Group)
+	order := make(map[string]int)
+	total := &Group{Key: "total"}
+	for _, act := range acts {
+		key, ord := groupKey(act, groupBy)
+		g, ok := byKey[key]
+		if !ok {
+			g = &Group{Key: key}
+			byKey[key] = g
+			order[key] = ord
+		}
+		for _, g := range []*Group{g, total} {
+			g.Activities++
+			g.Distance += act.Distance
+			g.Duration += act.ElapsedTime.Seconds()
+			g.MovingTime += act.MovingTime.Seconds()
+			g.ElevationGain += act.ElevationGain
+		}
+	}
+
+	res := make([]*Group, 0, len(byKey))
+	for _, g := range byKey {
+		res = append(res, g)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		g0, g1 := res[i], res[j]
+		if groupBy == "sport" && g0.Activities != g1.Activities {
+			return g0.Activities > g1.Activities
+		}
+		if o0, o1 := order[g0.Key], order[g1.Key]; o0 != o1 {
+			return o0 < o1
+		}
+		return g0.Key < g1.Key
+	})
+	for _, g := range append(res, total) {
+		g.finish()
+	}
+	return res, total
+}
+
+func groupKey(act *parse.Activity, groupBy string) (string, int) {
+	start := act.Records[0].Timestamp.Local()
+	switch groupBy {
+	case "week":
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week), 0
+	case "month":
+		return start.Format("2006-01"), 0
+	case "year":
+		return start.Format("2006"), 0
+	case "weekday":
+		return start.Weekday().String()[:3], (int(start.Weekday()) + 6) % 7
+	default:
+		if act.Sport == "" {
+			return "unknown", 0
+		}
+		return strings.ToLower(act.Sport), 0
+	}
+}
+
+func (g *Group) finish() {
+	g.Distance = round(g.Distance, 1)
+	if g.Distance > 0 {
+		g.Pace = round(g.MovingTime/(g.Distance/1000), 1)
+	}
+	g.Duration = round(g.Duration, 0)
+	g.MovingTime = round(g.MovingTime, 0)
+	g.ElevationGain = round(g.ElevationGain, 1)
+}
+
+func writeTable(w io.Writer, p *locale.Printer, groupBy string, groups []*Group, total *Group) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, label := range []string{groupBy, "activities", "distance", "duration", "moving time", "pace", "elevation gain"} {
+		fmt.Fprintf(tw, "%s\t", p.Sprintf(label))
+	}
+	fmt.Fprintln(tw)
+	for _, g := range append(groups, total) {
+		key := g.Key
+		if g == total || groupBy == "sport" || groupBy == "weekday" {
+			key = p.Sprintf(key)
+		}
+		pace := time.Duration(0)
+		if g.Distance > 0 {
+			pace = time.Duration(g.MovingTime / g.Distance * float64(time.Second))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			key,
+			p.Sprintf("%d", g.Activities),
+			p.Distance(g.Distance),
+			p.Duration(time.Duration(g.Duration)*time.Second),
+			p.Duration(time.Duration(g.MovingTime)*time.Second),
+			p.Pace(pace),
+			p.Elevation(g.ElevationGain),
+		)
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, groupBy string, groups []*Group) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{groupBy, "activities", "distance", "duration", "moving_time", "pace", "elevation_gain"})
+	for _, g := range groups {
+		_ = cw.Write([]string{
+			g.Key,
+			strconv.Itoa(g.Activities),
+			conv.FormatFloat(g.Distance),
+			conv.FormatFloat(g.Duration),
+			conv.FormatFloat(g.MovingTime),
+			conv.FormatFloat(g.Pace),
+			conv.FormatFloat(g.ElevationGain),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow10(places)
+	return math.Round(v*p) / p
+}
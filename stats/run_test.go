@@ -0,0 +1,97 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func testActivities() []*parse.Activity {
+	newAct := func(sport string, day int, dist float64, dur time.Duration) *parse.Activity {
+		return &parse.Activity{
+			Sport:         sport,
+			Distance:      dist,
+			ElapsedTime:   dur + time.Minute,
+			MovingTime:    dur,
+			ElevationGain: dist / 100,
+			Records:       []*parse.Record{{Timestamp: time.Date(2023, 1, day, 7, 0, 0, 0, time.Local)}},
+		}
+	}
+	return []*parse.Activity{
+		newAct("Running", 1, 5000, 25*time.Minute),
+		newAct("cycling", 2, 20000, 40*time.Minute),
+		newAct("running", 8, 10000, 55*time.Minute),
+		newAct("", 31, 1000, 10*time.Minute),
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	testCases := []struct {
+		groupBy string
+		keys    []string
+		counts  []int
+	}{
+		{"sport", []string{"running", "cycling", "unknown"}, []int{2, 1, 1}},
+		{"week", []string{"2022-W52", "2023-W01", "2023-W05"}, []int{1, 2, 1}},
+		{"month", []string{"2023-01"}, []int{4}},
+		{"year", []string{"2023"}, []int{4}},
+		{"weekday", []string{"Mon", "Tue", "Sun"}, []int{1, 1, 2}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
+			is := require.New(t)
+
+			groups, total := aggregate(testActivities(), tc.groupBy)
+			keys := make([]string, len(groups))
+			counts := make([]int, len(groups))
+			for i, g := range groups {
+				keys[i] = g.Key
+				counts[i] = g.Activities
+			}
+			is.Equal(tc.keys, keys)
+			is.Equal(tc.counts, counts)
+			is.Equal(4, total.Activities)
+			is.Equal(36000.0, total.Distance)
+			is.Equal(7800.0, total.MovingTime)
+			is.Equal(8040.0, total.Duration)
+			is.Equal(216.7, total.Pace)
+			is.Equal(360.0, total.ElevationGain)
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	is := require.New(t)
+
+	groups, _ := aggregate(testActivities(), "sport")
+	buf := &bytes.Buffer{}
+	is.NoError(writeCSV(buf, "sport", groups))
+	is.Equal(strings.Join([]string{
+		"sport,activities,distance,duration,moving_time,pace,elevation_gain",
+		"running,2,15000,4920,4800,320,150",
+		"cycling,1,20000,2460,2400,120,200",
+		"unknown,1,1000,660,600,600,10",
+		"",
+	}, "\n"), buf.String())
+}
+
+func TestWriteTable(t *testing.T) {
+	is := require.New(t)
+
+	groups, total := aggregate(testActivities(), "year")
+	buf := &bytes.Buffer{}
+	is.NoError(writeTable(buf, locale.NewPrinter(language.English, locale.Metric), "year", groups, total))
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	is.Len(lines, 3)
+	is.Equal([]string{"year", "activities", "distance", "duration", "moving", "time", "pace", "elevation", "gain"}, strings.Fields(lines[0]))
+	is.Equal([]string{"2023", "4", "36.0km", "2h14m0s", "2h10m0s", "3m36s/km", "360m"}, strings.Fields(lines[1]))
+	is.Equal("total", strings.Fields(lines[2])[0])
+}
@@ -2,21 +2,34 @@ package main
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/progress"
 	"github.com/NathanBaulch/rainbow-roads/worms"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/exp/slices"
+	"golang.org/x/text/language"
 )
 
 var (
 	wormsOpts = &worms.Options{
 		Title:   Title,
 		Version: Version,
+		Units:   locale.Metric,
+		Locale:  language.English,
 	}
 	wormsCmd = &cobra.Command{
 		Use:   "worms",
 		Short: "Animate exercise activities",
-		PreRunE: func(*cobra.Command, []string) error {
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			if wormsOpts.Index != "" && len(args) > 0 {
+				return flagError("index", wormsOpts.Index, "not supported with input paths")
+			}
+			if wormsOpts.Jobs < 0 {
+				return flagError("jobs", wormsOpts.Jobs, "must not be negative")
+			}
 			if wormsOpts.Frames == 0 {
 				return flagError("frames", wormsOpts.Frames, "must be positive")
 			}
@@ -29,14 +42,36 @@ var (
 			if wormsOpts.ColorDepth == 0 {
 				return flagError("color_depth", wormsOpts.ColorDepth, "must be positive")
 			}
+			if !slices.Contains(worms.ColorChannels, wormsOpts.ColorBy) {
+				return flagError("color_by", wormsOpts.ColorBy, "not supported")
+			}
+			if wormsOpts.Replay && wormsOpts.Loop {
+				return flagError("loop", "true", "not supported in replay mode")
+			}
+			if wormsOpts.Replay && wormsOpts.CollapsePauses {
+				return flagError("collapse_pauses", "true", "not supported in replay mode")
+			}
+			if !wormsOpts.Replay && wormsOpts.CompressGaps != 0 {
+				return flagError("compress_gaps", wormsOpts.CompressGaps, "only supported in replay mode")
+			}
+			if !wormsOpts.Replay && wormsOpts.FrameWindow != 0 {
+				return flagError("frame_window", wormsOpts.FrameWindow, "only supported in replay mode")
+			}
+			if wormsOpts.FontSize <= 0 {
+				return flagError("font_size", wormsOpts.FontSize, "must be positive")
+			}
 			if wormsOpts.Speed < 1 {
 				return flagError("speed", wormsOpts.Speed, "must be greater than or equal to 1")
 			}
-			return nil
+			return validateCleaner(&wormsOpts.Cleaner)
 		},
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			wormsOpts.Input = args
-			return worms.Run(wormsOpts)
+			wormsOpts.Cache = activityCache()
+			return withProgress(func(rep progress.Reporter) error {
+				wormsOpts.Progress = rep
+				return worms.Run(cmd.Context(), wormsOpts)
+			})
 		},
 	}
 )
@@ -47,7 +82,13 @@ func init() {
 	general := &pflag.FlagSet{}
 	general.StringVarP(&wormsOpts.Output, "output", "o", "out", "optional path of the generated file")
 	general.StringVarP(&wormsOpts.Format, "format", "f", "gif", "output file format string, supports gif, png, zip")
-	general.VisitAll(func(f *pflag.Flag) { wormsCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+	general.Var((*UnitsFlag)(&wormsOpts.Units), "units", "system of units used to print measurements, supports "+strings.Join(locale.UnitSystems, ", "))
+	general.Var((*LocaleFlag)(&wormsOpts.Locale), "locale", "language used to print numbers and labels, eg de, en-US")
+	general.StringVar(&wormsOpts.Index, "index", "", "optional path of an activity index to query instead of scanning input files")
+	general.StringVar(&wormsOpts.Report, "report", "", "optional path of a JSON report listing the outcome of every scanned file")
+	general.IntVar(&wormsOpts.Jobs, "jobs", 0, "number of files parsed and frames rendered concurrently, defaults to the number of CPUs")
+	general.BoolVar(&noCache, "no_cache", false, "disable the cache of parsed activities kept in the user cache directory")
+	general.VisitAll(wormsCmd.Flags().AddFlag)
 
 	rendering := &pflag.FlagSet{}
 	rendering.UintVar(&wormsOpts.Frames, "frames", 200, "number of animation frames")
@@ -56,13 +97,27 @@ func init() {
 	_ = wormsOpts.Colors.Parse("#fff,#ff8,#911,#414,#007@.5,#003")
 	rendering.Var((*ColorsFlag)(&wormsOpts.Colors), "colors", "CSS linear-colors inspired color scheme string, eg red,yellow,green,blue,black")
 	rendering.UintVar(&wormsOpts.ColorDepth, "color_depth", 5, "number of bits per color in the image palette")
+	rendering.StringVar(&wormsOpts.ColorBy, "color_by", "time", "data that colors represent, supports time, speed, pace, heart_rate, elevation, gradient, sport")
+	rendering.Var((*RangeFlag)(&wormsOpts.ColorRange), "color_range", "data range mapped onto the color scheme in km/h, min/km, bpm, m or %, eg 8,16")
 	rendering.Float64Var(&wormsOpts.Speed, "speed", 1.25, "how quickly activities should progress")
 	rendering.BoolVar(&wormsOpts.Loop, "loop", false, "start each activity sequentially and animate continuously")
+	rendering.BoolVar(&wormsOpts.Replay, "replay", false, "animate activities on a shared calendar timeline as they actually happened")
+	rendering.Var((*DurationFlag)(&wormsOpts.CompressGaps), "compress_gaps", "longest idle period between activities in replay mode, eg 12h")
+	rendering.BoolVar(&wormsOpts.CollapsePauses, "collapse_pauses", false, "skip over paused and stationary periods so worms advance by moving time only")
+	rendering.Var((*DurationFlag)(&wormsOpts.FrameWindow), "frame_window", "calendar time covered by each frame in replay mode, overrides frames, eg 24h")
+	rendering.StringVar(&wormsOpts.Caption, "caption", "", "custom title text stamped on each frame")
+	rendering.BoolVar(&wormsOpts.ShowDate, "show_date", false, "stamp each frame with the date being replayed, or elapsed time outside replay mode")
+	rendering.BoolVar(&wormsOpts.ShowDistance, "show_distance", false, "stamp each frame with the running distance total")
+	rendering.Float64Var(&wormsOpts.FontSize, "font_size", 16, "size of stamped text in points")
+	rendering.Var((*PositionFlag)(&wormsOpts.CaptionAt), "caption_position", "corner of stamped text, supports top_left, top_right, bottom_left, bottom_right")
 	rendering.BoolVar(&wormsOpts.NoWatermark, "no_watermark", false, "suppress the embedded project name and version string")
-	rendering.VisitAll(func(f *pflag.Flag) { wormsCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+	rendering.VisitAll(wormsCmd.Flags().AddFlag)
 
 	filters := filterFlagSet(&wormsOpts.Selector)
-	filters.VisitAll(func(f *pflag.Flag) { wormsCmd.Flags().Var(f.Value, f.Name, f.Usage) })
+	filters.VisitAll(wormsCmd.Flags().AddFlag)
+
+	cleaning := cleanFlagSet(&wormsOpts.Cleaner)
+	cleaning.VisitAll(wormsCmd.Flags().AddFlag)
 
 	wormsCmd.SetUsageFunc(func(*cobra.Command) error {
 		fmt.Fprintln(wormsCmd.OutOrStderr())
@@ -73,6 +128,8 @@ func init() {
 		fmt.Fprintln(wormsCmd.OutOrStderr(), general.FlagUsages())
 		fmt.Fprintln(wormsCmd.OutOrStderr(), "Filtering flags:")
 		fmt.Fprintln(wormsCmd.OutOrStderr(), filters.FlagUsages())
+		fmt.Fprintln(wormsCmd.OutOrStderr(), "Cleaning flags:")
+		fmt.Fprintln(wormsCmd.OutOrStderr(), cleaning.FlagUsages())
 		fmt.Fprintln(wormsCmd.OutOrStderr(), "Rendering flags:")
 		fmt.Fprint(wormsCmd.OutOrStderr(), rendering.FlagUsages())
 		return nil
@@ -0,0 +1,195 @@
+package worms
+
+import (
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/NathanBaulch/rainbow-roads/geo"
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+var ColorChannels = []string{"time", "speed", "pace", "heart_rate", "elevation", "gradient", "sport"}
+
+type colorScheme struct {
+	size   int
+	fades  int
+	values int
+}
+
+// newColorScheme lays out the palette either as a single time gradient or, when coloring by a data channel,
+// as a grid of gradient values repeated at progressively dimmer fade levels.
+func newColorScheme(channel string, depth uint) *colorScheme {
+	s := &colorScheme{size: 1<<depth - 2}
+	if channel != "" && channel != "time" {
+		s.fades = min(max(int(math.Round(math.Cbrt(float64(s.size)))), 2), 8)
+		s.values = max(s.size/s.fades, 1)
+	}
+	return s
+}
+
+func (s *colorScheme) palette(colors img.ColorGradient) color.Palette {
+	pal := color.Palette(make([]color.Color, s.size+2))
+	if s.values == 0 {
+		for i := 0; i < s.size; i++ {
+			pal[i] = colors.GetColorAt(float64(i) / float64(s.size-1))
+		}
+	} else {
+		for i := 0; i < s.size; i++ {
+			pal[i] = color.Black
+		}
+		for v := 0; v < s.values; v++ {
+			c, _ := colorful.MakeColor(colors.GetColorAt(s.position(v)))
+			for f := 0; f < s.fades; f++ {
+				k := 1 - float64(f)/float64(s.fades)
+				pal[f*s.values+v] = colorful.Color{R: c.R * k, G: c.G * k, B: c.B * k}
+			}
+		}
+	}
+	pal[s.size] = color.Black
+	pal[s.size+1] = color.Transparent
+	return pal
+}
+
+func (s *colorScheme) position(v int) float64 {
+	if s.values == 1 {
+		return 0
+	}
+	return float64(v) / float64(s.values-1)
+}
+
+// index returns the palette index of a segment drawn pc of the way through its fade with the given normalized value.
+func (s *colorScheme) index(pc, value float64) uint8 {
+	if s.values == 0 {
+		if pc >= 0 && pc < 1 {
+			return uint8(math.Sqrt(pc) * float64(s.size))
+		}
+		return uint8(s.size - 1)
+	}
+	f := s.fades - 1
+	if pc >= 0 && pc < 1 {
+		f = int(math.Sqrt(pc) * float64(s.fades))
+	}
+	return uint8(f*s.values + int(math.Round(value*float64(s.values-1))))
+}
+
+// dim returns the palette index used to glow around the given index, if any.
+func (s *colorScheme) dim(ci uint8) (uint8, bool) {
+	const sqrt2 = 1.414213562
+	if s.values == 0 {
+		i := float64(ci) * sqrt2
+		return uint8(i), i < float64(s.size)
+	}
+	f, v := int(ci)/s.values, int(ci)%s.values
+	f = max(f+1, int(float64(f)*sqrt2))
+	return uint8(f*s.values + v), f < s.fades
+}
+
+func channelRange(channel string, stats *parse.Stats) (float64, float64) {
+	const widen = 0.25
+	switch channel {
+	case "speed":
+		return (1 - widen) * 3.6e9 / float64(stats.MaxPace), (1 + widen) * 3.6e9 / float64(stats.MinPace)
+	case "pace":
+		return (1 - widen) * stats.MinPace.Minutes() * 1000, (1 + widen) * stats.MaxPace.Minutes() * 1000
+	case "heart_rate":
+		return stats.HeartRate.Min, stats.HeartRate.Max
+	case "elevation":
+		return stats.Elevation.Min, stats.Elevation.Max
+	case "gradient":
+		return -10, 10
+	case "sport":
+		return 0, float64(len(stats.SportCounts) - 1)
+	default:
+		return 0, 0
+	}
+}
+
+// channelValues computes the normalized channel value of each record, filling gaps with the nearest known value.
+func channelValues(channel string, act *parse.Activity, sports []string, lo, hi float64) []float64 {
+	vals := make([]float64, len(act.Records))
+	for i, r := range act.Records {
+		var prev *parse.Record
+		if i > 0 {
+			prev = act.Records[i-1]
+		}
+		v := channelValue(channel, act, prev, r, sports)
+		if hi > lo {
+			v = math.Max(0, math.Min(1, (v-lo)/(hi-lo)))
+		} else if !math.IsNaN(v) {
+			v = 0.5
+		}
+		vals[i] = v
+	}
+
+	last := math.NaN()
+	for i, v := range vals {
+		if math.IsNaN(v) {
+			vals[i] = last
+		} else {
+			last = v
+		}
+	}
+	last = 0
+	for i := len(vals) - 1; i >= 0; i-- {
+		if math.IsNaN(vals[i]) {
+			vals[i] = last
+		} else {
+			last = vals[i]
+		}
+	}
+	return vals
+}
+
+func channelValue(channel string, act *parse.Activity, prev, r *parse.Record, sports []string) float64 {
+	switch channel {
+	case "speed", "pace":
+		speed := r.Speed
+		if math.IsNaN(speed) && prev != nil {
+			if dt := r.Timestamp.Sub(prev.Timestamp).Seconds(); dt > 0 {
+				speed = geo.DistanceHaversine(prev.Position, r.Position) / dt
+			}
+		}
+		if channel == "speed" {
+			return speed * 3.6
+		} else if speed > 0 {
+			return 1000 / (60 * speed)
+		}
+	case "heart_rate":
+		return r.HeartRate
+	case "elevation":
+		return r.Elevation
+	case "gradient":
+		if prev != nil {
+			if d := geo.DistanceHaversine(prev.Position, r.Position); d >= 1 {
+				return 100 * (r.Elevation - prev.Elevation) / d
+			}
+		}
+	case "sport":
+		sport := strings.ToLower(act.Sport)
+		if sport == "" {
+			sport = "unknown"
+		}
+		for i, s := range sports {
+			if s == sport {
+				return float64(i)
+			}
+		}
+	}
+	return math.NaN()
+}
+
+func sortedSports(counts map[string]int) []string {
+	sports := make([]string, 0, len(counts))
+	for s := range counts {
+		sports = append(sports, s)
+	}
+	sort.Slice(sports, func(i, j int) bool {
+		c0, c1 := counts[sports[i]], counts[sports[j]]
+		return c0 > c1 || (c0 == c1 && sports[i] < sports[j])
+	})
+	return sports
+}
@@ -0,0 +1,74 @@
+package worms
+
+import (
+	"image/color"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/paulmach/orb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColorSchemeTime(t *testing.T) {
+	is := require.New(t)
+
+	g := img.ColorGradient{}
+	is.NoError(g.Parse("#fff,#000"))
+	s := newColorScheme("time", 5)
+	pal := s.palette(g)
+	is.Len(pal, 32)
+	is.Equal(color.Black, pal[30])
+	is.Equal(uint8(0), s.index(0, 0))
+	is.Equal(uint8(29), s.index(1, 0))
+	ci, ok := s.dim(10)
+	is.True(ok)
+	is.Equal(uint8(14), ci)
+	_, ok = s.dim(25)
+	is.False(ok)
+}
+
+func TestColorSchemeChannel(t *testing.T) {
+	is := require.New(t)
+
+	g := img.ColorGradient{}
+	is.NoError(g.Parse("#f00,#00f"))
+	s := newColorScheme("speed", 5)
+	pal := s.palette(g)
+	is.Len(pal, 32)
+	is.Equal(colorful.Color{R: 1}, pal[0])
+	is.InDelta(1.0/3, pal[29].(colorful.Color).B, 1e-9)
+	is.Equal(3, s.fades)
+	is.Equal(10, s.values)
+	is.Equal(uint8(9), s.index(0, 1))
+	is.Equal(uint8(25), s.index(1, 0.5))
+	ci, ok := s.dim(3)
+	is.True(ok)
+	is.Equal(uint8(13), ci)
+	_, ok = s.dim(23)
+	is.False(ok)
+}
+
+func TestChannelValues(t *testing.T) {
+	is := require.New(t)
+
+	ts := time.Now()
+	act := &parse.Activity{Records: []*parse.Record{
+		{Timestamp: ts, HeartRate: math.NaN()},
+		{Timestamp: ts.Add(time.Second), HeartRate: 120},
+		{Timestamp: ts.Add(2 * time.Second), HeartRate: math.NaN()},
+		{Timestamp: ts.Add(3 * time.Second), HeartRate: 200},
+	}}
+	is.Equal([]float64{0.2, 0.2, 0.2, 1}, channelValues("heart_rate", act, nil, 100, 200))
+
+	act = &parse.Activity{Records: []*parse.Record{
+		{Timestamp: ts, Position: orb.Point{0, 0}, Speed: math.NaN()},
+		{Timestamp: ts.Add(10 * time.Second), Position: orb.Point{0, 0.001}, Speed: math.NaN()},
+	}}
+	vals := channelValues("speed", act, nil, 0, 100)
+	is.InDelta(0.4, vals[1], 0.001)
+	is.Equal(vals[1], vals[0])
+}
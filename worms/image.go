@@ -1,12 +1,20 @@
 package worms
 
 import (
+	"archive/zip"
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"hash/crc32"
 	"image"
 	"image/color"
+	"image/gif"
+	"image/png"
 	"io"
+	"math"
+
+	"github.com/kettek/apng"
 )
 
 var grays = make([]color.Color, 0x100)
@@ -26,7 +34,10 @@ func drawFill(im *image.Paletted, ci uint8) {
 	}
 }
 
-type glowPlotter struct{ *image.Paletted }
+type glowPlotter struct {
+	*image.Paletted
+	dim func(ci uint8) (uint8, bool)
+}
 
 func (p *glowPlotter) Set(x, y int, c color.Color) {
 	p.SetColorIndex(x, y, c.(color.Gray).Y)
@@ -34,20 +45,17 @@ func (p *glowPlotter) Set(x, y int, c color.Color) {
 
 func (p *glowPlotter) SetColorIndex(x, y int, ci uint8) {
 	if p.setPixIfLower(x, y, ci) {
-		const sqrt2 = 1.414213562
-		if i := float64(ci) * sqrt2; i < float64(len(p.Palette)-2) {
-			ci = uint8(i)
+		if ci, ok := p.dim(ci); ok {
 			p.setPixIfLower(x-1, y, ci)
 			p.setPixIfLower(x, y-1, ci)
 			p.setPixIfLower(x+1, y, ci)
 			p.setPixIfLower(x, y+1, ci)
-		}
-		if i := float64(ci) * sqrt2; i < float64(len(p.Palette)-2) {
-			ci = uint8(i)
-			p.setPixIfLower(x-1, y-1, ci)
-			p.setPixIfLower(x-1, y+1, ci)
-			p.setPixIfLower(x+1, y-1, ci)
-			p.setPixIfLower(x+1, y+1, ci)
+			if ci, ok := p.dim(ci); ok {
+				p.setPixIfLower(x-1, y-1, ci)
+				p.setPixIfLower(x-1, y+1, ci)
+				p.setPixIfLower(x+1, y-1, ci)
+				p.setPixIfLower(x+1, y+1, ci)
+			}
 		}
 	}
 }
@@ -63,55 +71,235 @@ func (p *glowPlotter) setPixIfLower(x, y int, ci uint8) bool {
 	return false
 }
 
-func optimizeFrames(ims []*image.Paletted) {
-	if len(ims) == 0 {
-		return
+type frameOptimizer struct {
+	prev  *image.Paletted
+	trans []uint8
+}
+
+func (o *frameOptimizer) Optimize(im *image.Paletted) *image.Paletted {
+	if o.prev == nil {
+		o.prev = image.NewPaletted(im.Rect, im.Palette)
+		o.trans = []uint8{uint8(len(im.Palette) - 1)}
+		copy(o.prev.Pix, im.Pix)
+		return im
+	}
+
+	buf := o.prev
+	var same bool
+	var j0, x0, y0 int
+	var crop image.Rectangle
+	for j := 0; j <= len(im.Pix); j++ {
+		if j == 0 {
+			same = buf.Pix[j] == im.Pix[j]
+		} else if j == len(im.Pix) || (buf.Pix[j] == im.Pix[j]) != same {
+			x := j % im.Stride
+			y := j / im.Stride
+			if same {
+				for len(o.trans) < j-j0 {
+					o.trans = append(o.trans, o.trans...)
+				}
+				copy(im.Pix[j0:j], o.trans[:j-j0])
+			} else {
+				copy(buf.Pix[j0:j], im.Pix[j0:j])
+				var r image.Rectangle
+				if y > y0 {
+					r = image.Rect(0, y0, im.Stride, y+1)
+				} else {
+					r = image.Rect(x0, y0, x, y+1)
+				}
+				if crop.Empty() {
+					crop = r
+				} else {
+					crop = crop.Union(r)
+				}
+			}
+			same = !same
+			j0, x0, y0 = j, x, y
+		}
+	}
+	if crop.Empty() {
+		crop = image.Rect(0, 0, 1, 1)
+	}
+	return im.SubImage(crop).(*image.Paletted)
+}
+
+type frameEncoder interface {
+	Encode(im *image.Paletted) error
+	Close() error
+}
+
+type gifEncoder struct {
+	w      *gifWriter
+	config image.Config
+	delay  int
+	opt    frameOptimizer
+	buf    bytes.Buffer
+	header bool
+}
+
+func newGIFEncoder(w io.Writer, comment string, pal color.Palette, width, height int, fps uint) *gifEncoder {
+	return &gifEncoder{
+		w:      &gifWriter{Writer: bufio.NewWriter(w), Comment: comment},
+		config: image.Config{ColorModel: pal, Width: width, Height: height},
+		delay:  int(math.Round(100 / float64(fps))),
+	}
+}
+
+func (e *gifEncoder) Encode(im *image.Paletted) error {
+	e.buf.Reset()
+	if err := gif.EncodeAll(&e.buf, &gif.GIF{
+		Image:    []*image.Paletted{e.opt.Optimize(im)},
+		Delay:    []int{e.delay},
+		Disposal: []byte{gif.DisposalNone},
+		Config:   e.config,
+	}); err != nil {
+		return err
+	}
+
+	b := e.buf.Bytes()
+	n := 13
+	if b[10]&0x80 != 0 {
+		n += 3 << (b[10]&0x07 + 1)
+	}
+	if !e.header {
+		if _, err := e.w.Write(b[:n]); err != nil {
+			return err
+		}
+		// infinite loop application extension
+		if _, err := e.w.Write([]byte{0x21, 0xff, 0x0b}); err != nil {
+			return err
+		}
+		if _, err := e.w.Write([]byte("NETSCAPE2.0\x03\x01\x00\x00\x00")); err != nil {
+			return err
+		}
+		e.header = true
+	}
+	// strip header and trailer
+	_, err := e.w.Write(b[n : len(b)-1])
+	return err
+}
+
+func (e *gifEncoder) Close() error {
+	if err := e.w.WriteByte(0x3b); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+type apngEncoder struct {
+	w      *pngWriter
+	frames uint
+	fps    uint
+	opt    frameOptimizer
+	enc    png.Encoder
+	buf    bytes.Buffer
+	count  uint
+	seq    uint32
+}
+
+func newAPNGEncoder(w io.Writer, text string, frames, fps uint) *apngEncoder {
+	return &apngEncoder{
+		w:      &pngWriter{Writer: w, Text: text},
+		frames: frames,
+		fps:    fps,
+	}
+}
+
+func (e *apngEncoder) Encode(im *image.Paletted) error {
+	im = e.opt.Optimize(im)
+	e.buf.Reset()
+	if err := e.enc.Encode(&e.buf, im); err != nil {
+		return err
 	}
 
-	buf := image.NewPaletted(ims[0].Rect, ims[0].Palette)
-	trans := []uint8{uint8(len(ims[0].Palette) - 1)}
-	for i, im := range ims {
-		if i == 0 {
-			copy(buf.Pix, im.Pix)
-		} else {
-			var same bool
-			var j0, x0, y0 int
-			var crop image.Rectangle
-			for j := 0; j <= len(im.Pix); j++ {
-				if j == 0 {
-					same = buf.Pix[j] == im.Pix[j]
-				} else if j == len(im.Pix) || (buf.Pix[j] == im.Pix[j]) != same {
-					x := j % im.Stride
-					y := j / im.Stride
-					if same {
-						for len(trans) < j-j0 {
-							trans = append(trans, trans...)
-						}
-						copy(im.Pix[j0:j], trans[:j-j0])
-					} else {
-						copy(buf.Pix[j0:j], im.Pix[j0:j])
-						var r image.Rectangle
-						if y > y0 {
-							r = image.Rect(0, y0, im.Stride, y+1)
-						} else {
-							r = image.Rect(x0, y0, x, y+1)
-						}
-						if crop.Empty() {
-							crop = r
-						} else {
-							crop = crop.Union(r)
-						}
-					}
-					same = !same
-					j0, x0, y0 = j, x, y
+	b := e.buf.Bytes()
+	first := e.count == 0
+	if first {
+		if _, err := e.w.Write(b[:8]); err != nil {
+			return err
+		}
+	}
+	b = b[8:]
+	e.count++
+
+	control := false
+	for len(b) >= 12 {
+		n := binary.BigEndian.Uint32(b)
+		chunk, name, data := b[:12+n], string(b[4:8]), b[8:8+n]
+		b = b[12+n:]
+		switch name {
+		case "IHDR", "PLTE", "tRNS":
+			if !first {
+				continue
+			}
+			if _, err := e.w.Write(chunk); err != nil {
+				return err
+			}
+			if name == "IHDR" {
+				ctl := make([]byte, 8)
+				binary.BigEndian.PutUint32(ctl[0:4], uint32(e.frames))
+				if _, err := e.w.writeChunk(ctl, "acTL"); err != nil {
+					return err
+				}
+			}
+		case "IDAT":
+			if !control {
+				if err := e.writeFrameControl(im); err != nil {
+					return err
 				}
+				control = true
 			}
-			if crop.Empty() {
-				crop = image.Rect(0, 0, 1, 1)
+			if first {
+				if _, err := e.w.Write(chunk); err != nil {
+					return err
+				}
+			} else {
+				dat := make([]byte, 4+len(data))
+				binary.BigEndian.PutUint32(dat[0:4], e.seq)
+				e.seq++
+				copy(dat[4:], data)
+				if _, err := e.w.writeChunk(dat, "fdAT"); err != nil {
+					return err
+				}
 			}
-			ims[i] = im.SubImage(crop).(*image.Paletted)
 		}
 	}
+	return nil
+}
+
+func (e *apngEncoder) writeFrameControl(im *image.Paletted) error {
+	ctl := make([]byte, 26)
+	binary.BigEndian.PutUint32(ctl[0:4], e.seq)
+	e.seq++
+	binary.BigEndian.PutUint32(ctl[4:8], uint32(im.Rect.Dx()))
+	binary.BigEndian.PutUint32(ctl[8:12], uint32(im.Rect.Dy()))
+	binary.BigEndian.PutUint32(ctl[12:16], uint32(im.Rect.Min.X))
+	binary.BigEndian.PutUint32(ctl[16:20], uint32(im.Rect.Min.Y))
+	binary.BigEndian.PutUint16(ctl[20:22], 1)
+	binary.BigEndian.PutUint16(ctl[22:24], uint16(e.fps))
+	ctl[24] = apng.DISPOSE_OP_NONE
+	ctl[25] = apng.BLEND_OP_OVER
+	_, err := e.w.writeChunk(ctl, "fcTL")
+	return err
+}
+
+func (e *apngEncoder) Close() error {
+	_, err := e.w.writeChunk(nil, "IEND")
+	return err
+}
+
+type zipEncoder struct {
+	*zip.Writer
+	count int
+}
+
+func (e *zipEncoder) Encode(im *image.Paletted) error {
+	w, err := e.Create(fmt.Sprintf("%d.gif", e.count))
+	if err != nil {
+		return err
+	}
+	e.count++
+	return gif.Encode(w, im, nil)
 }
 
 type gifWriter struct {
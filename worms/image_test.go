@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"image"
 	"image/color"
+	"image/gif"
 	"testing"
 
+	"github.com/kettek/apng"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,7 +26,10 @@ func TestImageOptimizeFrames(t *testing.T) {
 	ims[4].Pix = []byte{0, 1, 0, 1, 0, 1, 0, 1, 0}
 	ims[5].Pix = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0}
 	ims[6].Pix = []byte{0, 0, 1, 1, 0, 0, 0, 0, 0}
-	optimizeFrames(ims)
+	opt := &frameOptimizer{}
+	for i, im := range ims {
+		ims[i] = opt.Optimize(im)
+	}
 	expects := []struct {
 		size image.Rectangle
 		pix  []uint8
@@ -70,3 +75,47 @@ func TestImagePngWriter(t *testing.T) {
 	is.Equal(23, n)
 	is.True(bytes.Contains(b.Bytes(), []byte("foo")))
 }
+
+func TestImageGIFEncoder(t *testing.T) {
+	is := require.New(t)
+
+	b := &bytes.Buffer{}
+	pal := color.Palette([]color.Color{color.Black, color.White, color.Transparent})
+	enc := newGIFEncoder(b, "foo", pal, 3, 3, 10)
+	for i := 0; i < 3; i++ {
+		im := image.NewPaletted(image.Rect(0, 0, 3, 3), pal)
+		im.Pix[i] = 1
+		is.NoError(enc.Encode(im))
+	}
+	is.NoError(enc.Close())
+
+	g, err := gif.DecodeAll(b)
+	is.NoError(err)
+	is.Len(g.Image, 3)
+	is.Equal(0, g.LoopCount)
+	is.Equal([]int{10, 10, 10}, g.Delay)
+	is.Equal(image.Rect(0, 0, 3, 3), g.Image[0].Rect)
+	is.Equal(image.Rect(0, 0, 2, 1), g.Image[1].Rect)
+}
+
+func TestImageAPNGEncoder(t *testing.T) {
+	is := require.New(t)
+
+	b := &bytes.Buffer{}
+	pal := color.Palette([]color.Color{color.Black, color.White, color.Transparent})
+	enc := newAPNGEncoder(b, "foo", 3, 10)
+	for i := 0; i < 3; i++ {
+		im := image.NewPaletted(image.Rect(0, 0, 3, 3), pal)
+		im.Pix[i*4] = 1
+		is.NoError(enc.Encode(im))
+	}
+	is.NoError(enc.Close())
+	is.True(bytes.Contains(b.Bytes(), []byte("foo")))
+
+	a, err := apng.DecodeAll(b)
+	is.NoError(err)
+	is.Len(a.Frames, 3)
+	is.Equal(image.Rect(0, 0, 3, 3), a.Frames[0].Image.Bounds())
+	is.Equal(image.Rect(0, 0, 2, 2), a.Frames[1].Image.Bounds())
+	is.Equal(uint16(10), a.Frames[2].DelayDenominator)
+}
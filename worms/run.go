@@ -2,70 +2,105 @@ package worms
 
 import (
 	"archive/zip"
-	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"image/gif"
 	"io"
 	"io/fs"
-	"log"
 	"math"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/NathanBaulch/rainbow-roads/geo"
 	"github.com/NathanBaulch/rainbow-roads/img"
+	"github.com/NathanBaulch/rainbow-roads/index"
+	"github.com/NathanBaulch/rainbow-roads/locale"
+	"github.com/NathanBaulch/rainbow-roads/output"
 	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
 	"github.com/NathanBaulch/rainbow-roads/scan"
 	"github.com/StephaneBunel/bresenham"
-	"github.com/kettek/apng"
-	"github.com/paulmach/orb"
 	"github.com/paulmach/orb/project"
+	"golang.org/x/image/font"
 	"golang.org/x/text/language"
-	"golang.org/x/text/message"
 )
 
-var (
-	o          *Options
-	fullTitle  string
-	en         = message.NewPrinter(language.English)
-	files      []*scan.File
-	activities []*parse.Activity
-	maxDur     time.Duration
-	extent     orb.Bound
-	images     []*image.Paletted
-)
+var errNotLoaded = errors.New("no activities loaded")
 
 type Options struct {
-	Title       string
-	Version     string
-	Input       []string
-	Output      string
-	Width       uint
-	Frames      uint
-	FPS         uint
-	Format      string
-	Colors      img.ColorGradient
-	ColorDepth  uint
-	Speed       float64
-	Loop        bool
-	NoWatermark bool
-	Selector    parse.Selector
+	Title          string
+	Version        string
+	Input          []string
+	Index          string
+	Output         string
+	Report         string
+	Width          uint
+	Frames         uint
+	FPS            uint
+	Format         string
+	Colors         img.ColorGradient
+	ColorDepth     uint
+	ColorBy        string
+	ColorRange     [2]float64
+	Speed          float64
+	Loop           bool
+	Replay         bool
+	CompressGaps   time.Duration
+	CollapsePauses bool
+	FrameWindow    time.Duration
+	Caption        string
+	ShowDate       bool
+	ShowDistance   bool
+	FontSize       float64
+	CaptionAt      img.Position
+	NoWatermark    bool
+	Units          locale.Units
+	Locale         language.Tag
+	Jobs           int
+	Progress       progress.Reporter
+	Cache          *parse.Cache
+	Selector       parse.Selector
+	Cleaner        parse.Cleaner
 }
 
-func Run(opts *Options) error {
-	o = opts
+// Renderer animates activities into frames. All state is held per Renderer, including its own copies of
+// the activities it selects, so independent renderers may be used concurrently even with shared activities.
+type Renderer struct {
+	o          Options
+	fullTitle  string
+	printer    *locale.Printer
+	activities []*parse.Activity
+	stats      *parse.Stats
+	scheme     *colorScheme
+	values     [][]float64
+	tline      *timeline
+	maxDur     time.Duration
+	face       font.Face
+	background *image.Paletted
+}
 
-	fullTitle = "NathanBaulch/" + o.Title
-	if o.Version != "" {
-		fullTitle += " " + o.Version
+// NewRenderer creates a Renderer from a copy of the given options.
+func NewRenderer(opts *Options) *Renderer {
+	r := &Renderer{o: *opts}
+	if r.o.Format == "" {
+		r.o.Format = "gif"
+	}
+	r.printer = locale.NewPrinter(r.o.Locale, r.o.Units)
+	r.fullTitle = "NathanBaulch/" + r.o.Title
+	if r.o.Version != "" {
+		r.fullTitle += " " + r.o.Version
 	}
+	return r
+}
 
+func Run(ctx context.Context, opts *Options) error {
+	o := *opts
 	if len(o.Input) == 0 {
 		o.Input = []string{"."}
 	}
@@ -82,7 +117,7 @@ func Run(opts *Options) error {
 	if ext != "" {
 		ext = ext[1:]
 		if o.Format == "" {
-			o.Format = ext[1:]
+			o.Format = ext
 		}
 	}
 	if o.Format == "" {
@@ -92,38 +127,76 @@ func Run(opts *Options) error {
 		o.Output += "." + o.Format
 	}
 
-	for _, step := range []func() error{scanStep, parseStep, renderStep, saveStep} {
-		if err := step(); err != nil {
+	r := NewRenderer(&o)
+
+	var (
+		stats *parse.Stats
+		diags parse.Diagnostics
+		err   error
+	)
+	if o.Index != "" {
+		var ix *index.Index
+		if ix, err = index.Open(o.Index); err != nil {
 			return err
 		}
+		r.printer.Field("files", "%d", len(ix.Files))
+		stats, diags, err = r.Select(ix.Activities())
+	} else {
+		var files []*scan.File
+		if files, err = scan.Scan(ctx, o.Input, o.Progress); err != nil {
+			return err
+		}
+		r.printer.Field("files", "%d", len(files))
+		stats, diags, err = r.Load(ctx, files)
+	}
+	if diags != nil {
+		diags.Print(r.printer)
+		if o.Report != "" {
+			if err := output.Create(o.Report, diags.WriteJSON); err != nil {
+				return err
+			}
+		}
 	}
+	if err != nil {
+		return err
+	}
+	stats.Print(r.printer)
 
-	return nil
+	return output.Create(o.Output, func(w io.Writer) error { return r.Encode(ctx, w) })
 }
 
-func scanStep() error {
-	if f, err := scan.Scan(o.Input); err != nil {
-		return err
-	} else {
-		files = f
-		en.Println("files:        ", len(files))
-		return nil
+// Load parses the given files, prepares them for rendering and returns their stats along with the outcome of each file.
+func (r *Renderer) Load(ctx context.Context, files []*scan.File) (*parse.Stats, parse.Diagnostics, error) {
+	activities, stats, diags, err := parse.Parse(ctx, files, &r.o.Selector, &r.o.Cleaner, &parse.Options{Jobs: r.o.Jobs, Progress: r.o.Progress, Cache: r.o.Cache})
+	if err != nil {
+		return nil, diags, err
+	}
+	r.activities = activities
+	r.stats = stats
+	if err := r.prepare(); err != nil {
+		return nil, diags, err
 	}
+	return stats, diags, nil
 }
 
-func parseStep() error {
-	if a, stats, err := parse.Parse(files, &o.Selector); err != nil {
-		return err
-	} else {
-		activities = a
-		extent = stats.Extent
-		maxDur = stats.MaxDuration
-		stats.Print(en)
-		return nil
+// Select prepares previously decoded activities for rendering, such as those loaded from an index.
+func (r *Renderer) Select(acts []*parse.Activity) (*parse.Stats, parse.Diagnostics, error) {
+	activities, stats, diags, err := parse.Select(acts, &r.o.Selector, &r.o.Cleaner)
+	if err != nil {
+		return nil, diags, err
 	}
+	r.activities = activities
+	r.stats = stats
+	if err := r.prepare(); err != nil {
+		return nil, diags, err
+	}
+	return stats, diags, nil
 }
 
-func renderStep() error {
+func (r *Renderer) prepare() error {
+	o := &r.o
+	activities, stats := r.activities, r.stats
+
 	if o.Loop {
 		sort.Slice(activities, func(i, j int) bool {
 			return activities[i].Records[0].Timestamp.Before(activities[j].Records[0].Timestamp)
@@ -131,159 +204,215 @@ func renderStep() error {
 	}
 
 	proj := project.WGS84.ToMercator
-	ext := project.Bound(extent, proj)
+	ext := project.Bound(stats.Extent, proj)
 	dX, dY := ext.Right()-ext.Left(), ext.Top()-ext.Bottom()
 	scale := float64(o.Width) / dX
 	height := uint(dY * scale)
 	scale *= 0.9
 	ext.Min[0] -= 0.05 * dX
 	ext.Max[1] += 0.05 * dY
-	tScale := 1 / (o.Speed * float64(maxDur))
+	r.maxDur = stats.MaxDuration
+	if o.CollapsePauses {
+		r.maxDur = 0
+		for _, act := range activities {
+			r.maxDur = max(r.maxDur, act.MovingTime)
+		}
+	}
+	tScale := 1 / (o.Speed * float64(r.maxDur))
+	r.tline = nil
+	if o.Replay {
+		r.tline = newTimeline(activities, o.CompressGaps)
+		tScale = 1 / float64(r.tline.duration())
+		if o.FrameWindow > 0 {
+			o.Frames = uint(max(math.Ceil(float64(r.tline.duration())/float64(o.FrameWindow)), 1))
+		}
+	}
 	for i, act := range activities {
 		ts0 := act.Records[0].Timestamp
 		tOffset := 0.0
 		if o.Loop {
 			tOffset = float64(i) / float64(len(activities))
 		}
-		for _, r := range act.Records {
-			p := project.Point(r.Position, proj)
-			r.X = int((p.X() - ext.Left()) * scale)
-			r.Y = int((ext.Top() - p.Y()) * scale)
-			r.Percent = tOffset + float64(r.Timestamp.Sub(ts0))*tScale
+		for _, rec := range act.Records {
+			p := project.Point(rec.Position, proj)
+			rec.X = int((p.X() - ext.Left()) * scale)
+			rec.Y = int((ext.Top() - p.Y()) * scale)
+			if r.tline != nil {
+				rec.Percent = float64(r.tline.offset(rec.Timestamp)) * tScale
+			} else if o.CollapsePauses {
+				rec.Percent = tOffset + float64(act.MovingOffset(rec.Timestamp))*tScale
+			} else {
+				rec.Percent = tOffset + float64(rec.Timestamp.Sub(ts0))*tScale
+			}
 		}
 	}
 
-	pal := color.Palette(make([]color.Color, 1<<o.ColorDepth))
-	for i := 0; i < len(pal)-2; i++ {
-		pal[i] = o.Colors.GetColorAt(float64(i) / float64(len(pal)-3))
+	r.scheme = newColorScheme(o.ColorBy, o.ColorDepth)
+	r.values = nil
+	if r.scheme.values > 0 {
+		lo, hi := o.ColorRange[0], o.ColorRange[1]
+		if lo == 0 && hi == 0 {
+			lo, hi = channelRange(o.ColorBy, stats)
+		}
+		sports := sortedSports(stats.SportCounts)
+		r.values = make([][]float64, len(activities))
+		for i, act := range activities {
+			r.values[i] = channelValues(o.ColorBy, act, sports, lo, hi)
+		}
 	}
-	pal[len(pal)-2] = color.Black
-	pal[len(pal)-1] = color.Transparent
 
-	images = make([]*image.Paletted, o.Frames)
-	for i := range images {
-		im := image.NewPaletted(image.Rect(0, 0, int(o.Width), int(height)), pal)
-		if i == 0 {
-			drawFill(im, uint8(len(pal)-2))
-			if !o.NoWatermark {
-				img.DrawWatermark(im, fullTitle, pal[len(pal)/2])
-			}
-		} else {
-			copy(im.Pix, images[0].Pix)
+	pal := r.scheme.palette(o.Colors)
+
+	r.background = image.NewPaletted(image.Rect(0, 0, int(o.Width), int(height)), pal)
+	drawFill(r.background, uint8(len(pal)-2))
+	if !o.NoWatermark {
+		img.DrawWatermark(r.background, r.fullTitle, pal[len(pal)/2])
+	}
+
+	r.face = nil
+	if o.Caption != "" || o.ShowDate || o.ShowDistance {
+		var err error
+		if r.face, err = img.NewFace(o.FontSize); err != nil {
+			return err
 		}
-		images[i] = im
-	}
-
-	wg := &sync.WaitGroup{}
-	wg.Add(int(o.Frames))
-	for f := uint(0); f < o.Frames; f++ {
-		go func() {
-			fpc := float64(f+1) / float64(o.Frames)
-			gp := &glowPlotter{images[f]}
-			for _, act := range activities {
-				var rPrev *parse.Record
-				for _, r := range act.Records {
-					pc := fpc - r.Percent
-					if pc < 0 {
-						if !o.Loop {
-							break
-						}
-						pc++
-					}
-					if rPrev != nil && (r.X != rPrev.X || r.Y != rPrev.Y) {
-						ci := uint8(len(pal) - 3)
-						if pc >= 0 && pc < 1 {
-							ci = uint8(math.Sqrt(pc) * float64(len(pal)-2))
-						}
-						bresenham.DrawLine(gp, rPrev.X, rPrev.Y, r.X, r.Y, grays[ci])
-					}
-					rPrev = r
-				}
-			}
-			wg.Done()
-		}()
 	}
-	wg.Wait()
 
 	return nil
 }
 
-func saveStep() error {
-	if dir := filepath.Dir(o.Output); dir != "." {
-		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-			return err
-		}
+// Encode renders every frame and writes the animation to w in the configured format.
+func (r *Renderer) Encode(ctx context.Context, w io.Writer) error {
+	if r.background == nil {
+		return errNotLoaded
 	}
 
-	out, err := os.Create(o.Output)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err := out.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
+	pw := &progress.Writer{Writer: w, Reporter: r.o.Progress, Stage: progress.Encode}
+	w = pw
 
-	switch o.Format {
+	var enc frameEncoder
+	switch r.o.Format {
 	case "gif":
-		return saveGIF(out)
+		enc = newGIFEncoder(w, r.fullTitle, r.background.Palette, r.background.Rect.Dx(), r.background.Rect.Dy(), r.o.FPS)
 	case "png":
-		return savePNG(out)
+		enc = newAPNGEncoder(w, r.fullTitle, r.o.Frames, r.o.FPS)
 	case "zip":
-		return saveZIP(out)
+		enc = &zipEncoder{Writer: zip.NewWriter(w)}
 	default:
-		return nil
+		return fmt.Errorf("format %q not supported", r.o.Format)
 	}
-}
 
-func saveGIF(w io.Writer) error {
-	optimizeFrames(images)
-	g := &gif.GIF{
-		Image:    images,
-		Delay:    make([]int, len(images)),
-		Disposal: make([]byte, len(images)),
-		Config: image.Config{
-			ColorModel: images[0].Palette,
-			Width:      images[0].Rect.Max.X,
-			Height:     images[0].Rect.Max.Y,
-		},
-	}
-	d := int(math.Round(100 / float64(o.FPS)))
-	for i := range images {
-		g.Disposal[i] = gif.DisposalNone
-		g.Delay[i] = d
-	}
-	return gif.EncodeAll(&gifWriter{Writer: bufio.NewWriter(w), Comment: fullTitle}, g)
+	if err := r.Frames(ctx, enc.Encode); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	pw.Done()
+	return nil
 }
 
-func savePNG(w io.Writer) error {
-	optimizeFrames(images)
-	a := apng.APNG{Frames: make([]apng.Frame, len(images))}
-	for i, im := range images {
-		a.Frames[i].Image = im
-		a.Frames[i].XOffset = im.Rect.Min.X
-		a.Frames[i].YOffset = im.Rect.Min.Y
-		a.Frames[i].BlendOp = apng.BLEND_OP_OVER
-		a.Frames[i].DelayNumerator = 1
-		a.Frames[i].DelayDenominator = uint16(o.FPS)
-	}
-	return apng.Encode(&pngWriter{Writer: w, Text: fullTitle}, a)
-}
+// Frames renders each frame in order and passes it to fn.
+// Frames are reused between calls, so fn must not retain them.
+func (r *Renderer) Frames(ctx context.Context, fn func(im *image.Paletted) error) error {
+	if r.background == nil {
+		return errNotLoaded
+	}
 
-func saveZIP(w io.Writer) error {
-	z := zip.NewWriter(w)
-	defer func() {
-		if err := z.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-	for i, im := range images {
-		if w, err := z.Create(fmt.Sprintf("%d.gif", i)); err != nil {
-			return err
-		} else if err = gif.Encode(w, im, nil); err != nil {
+	o, background := &r.o, r.background
+	jobs := o.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	batch := make([]*image.Paletted, min(jobs, int(o.Frames)))
+	for i := range batch {
+		batch[i] = image.NewPaletted(background.Rect, background.Palette)
+	}
+
+	for f0 := uint(0); f0 < o.Frames; f0 += uint(len(batch)) {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+
+		ims := batch[:min(uint(len(batch)), o.Frames-f0)]
+		dists := make([]float64, len(ims))
+		wg := &sync.WaitGroup{}
+		wg.Add(len(ims))
+		for i, im := range ims {
+			go func() {
+				copy(im.Pix, background.Pix)
+				dists[i] = r.drawFrame(im, f0+uint(i))
+				wg.Done()
+			}()
+		}
+		wg.Wait()
+
+		for i, im := range ims {
+			if r.face != nil {
+				img.DrawText(im, r.face, im.Palette[0], o.CaptionAt, r.captionLines(f0+uint(i), dists[i])...)
+			}
+			if err := fn(im); err != nil {
+				return err
+			}
+			progress.Report(o.Progress, progress.Render, int64(f0)+int64(i)+1, int64(o.Frames))
+		}
 	}
+
 	return nil
 }
+
+// drawFrame draws the worms of the given frame and returns the total distance covered so far.
+func (r *Renderer) drawFrame(im *image.Paletted, f uint) float64 {
+	o, scheme, values := &r.o, r.scheme, r.values
+	dist := 0.0
+	fpc := float64(f+1) / float64(o.Frames)
+	gp := &glowPlotter{Paletted: im, dim: scheme.dim}
+	for i, act := range r.activities {
+		var prev *parse.Record
+		for j, rec := range act.Records {
+			pc := fpc - rec.Percent
+			if pc < 0 {
+				if !o.Loop {
+					break
+				}
+				pc++
+			}
+			if prev != nil && (rec.X != prev.X || rec.Y != prev.Y) {
+				v := 0.0
+				if values != nil {
+					v = values[i][j]
+				}
+				ci := scheme.index(pc, v)
+				bresenham.DrawLine(gp, prev.X, prev.Y, rec.X, rec.Y, grays[ci])
+			}
+			if prev != nil && o.ShowDistance {
+				dist += geo.DistanceHaversine(prev.Position, rec.Position)
+			}
+			prev = rec
+		}
+	}
+	return dist
+}
+
+func (r *Renderer) captionLines(f uint, dist float64) []string {
+	o, tline := &r.o, r.tline
+	lines := make([]string, 0, 3)
+	if o.Caption != "" {
+		lines = append(lines, o.Caption)
+	}
+	if o.ShowDate {
+		fpc := float64(f+1) / float64(o.Frames)
+		if tline != nil {
+			layout := "2006-01-02 15:04"
+			if o.FrameWindow >= 24*time.Hour {
+				layout = "2006-01-02"
+			}
+			lines = append(lines, tline.time(time.Duration(fpc*float64(tline.duration()))).Format(layout))
+		} else {
+			lines = append(lines, time.Duration(fpc*o.Speed*float64(r.maxDur)).Truncate(time.Second).String())
+		}
+	}
+	if o.ShowDistance {
+		lines = append(lines, r.printer.Distance(dist))
+	}
+	return lines
+}
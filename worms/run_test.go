@@ -0,0 +1,171 @@
+package worms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image/gif"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/NathanBaulch/rainbow-roads/progress"
+	"github.com/NathanBaulch/rainbow-roads/scan"
+	"github.com/stretchr/testify/require"
+)
+
+func testFiles() []*scan.File {
+	ts0 := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	sb := &strings.Builder{}
+	sb.WriteString(`<gpx><trk><type>running</type><trkseg>`)
+	for i := 0; i <= 80; i++ {
+		fmt.Fprintf(sb, `<trkpt lat="%.5f" lon="%.5f"><time>%s</time></trkpt>`, -37.8+0.00005*float64(i), 144.896+0.0001*float64(i), ts0.Add(time.Duration(i)*3*time.Second).Format(time.RFC3339))
+	}
+	sb.WriteString(`</trkseg></trk></gpx>`)
+	data := sb.String()
+	return []*scan.File{{Name: "run.gpx", Ext: ".gpx", Opener: func() (io.Reader, error) { return strings.NewReader(data), nil }}}
+}
+
+func testOptions() *Options {
+	opts := &Options{Width: 100, Frames: 4, FPS: 10, ColorDepth: 4, Speed: 1.25, NoWatermark: true}
+	_ = opts.Colors.Parse("#fff,#f00,#000")
+	return opts
+}
+
+func TestRenderer(t *testing.T) {
+	is := require.New(t)
+
+	bufs := make([]bytes.Buffer, 4)
+	errs := make([]error, len(bufs))
+	wg := &sync.WaitGroup{}
+	for i := range bufs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := NewRenderer(testOptions())
+			if _, _, errs[i] = r.Load(context.Background(), testFiles()); errs[i] == nil {
+				errs[i] = r.Encode(context.Background(), &bufs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		is.NoError(err)
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(bufs[0].Bytes()))
+	is.NoError(err)
+	is.Len(g.Image, 4)
+	is.Equal(100, g.Config.Width)
+	for _, buf := range bufs[1:] {
+		is.Equal(bufs[0].Bytes(), buf.Bytes())
+	}
+}
+
+func TestRendererSharedActivities(t *testing.T) {
+	is := require.New(t)
+
+	acts, _, err := parse.Decode(context.Background(), testFiles(), nil)
+	is.NoError(err)
+	want := acts[0].Records[len(acts[0].Records)/2].Position
+
+	bufs := make([]bytes.Buffer, 4)
+	errs := make([]error, len(bufs))
+	wg := &sync.WaitGroup{}
+	for i := range bufs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts := testOptions()
+			opts.Cleaner.Smoother = "moving_average"
+			opts.Cleaner.Window = 3
+			r := NewRenderer(opts)
+			if _, _, errs[i] = r.Select(acts); errs[i] == nil {
+				errs[i] = r.Encode(context.Background(), &bufs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		is.NoError(err)
+	}
+	for _, buf := range bufs[1:] {
+		is.Equal(bufs[0].Bytes(), buf.Bytes())
+	}
+
+	mid := acts[0].Records[len(acts[0].Records)/2]
+	is.Equal(want, mid.Position)
+	is.Zero(mid.X)
+	is.Zero(mid.Percent)
+}
+
+func TestRendererCollapsePauses(t *testing.T) {
+	is := require.New(t)
+
+	ts0 := time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	sb := &strings.Builder{}
+	sb.WriteString(`<gpx><trk><type>running</type><trkseg>`)
+	for i := 0; i <= 80; i++ {
+		ts := ts0.Add(time.Duration(i) * 3 * time.Second)
+		if i > 40 {
+			ts = ts.Add(10 * time.Minute)
+		}
+		fmt.Fprintf(sb, `<trkpt lat="%.5f" lon="%.5f"><time>%s</time></trkpt>`, -37.8+0.00005*float64(i), 144.896+0.0001*float64(i), ts.Format(time.RFC3339))
+	}
+	sb.WriteString(`</trkseg></trk></gpx>`)
+	data := sb.String()
+	files := []*scan.File{{Name: "run.gpx", Ext: ".gpx", Opener: func() (io.Reader, error) { return strings.NewReader(data), nil }}}
+
+	opts := testOptions()
+	opts.Speed = 1
+	opts.CollapsePauses = true
+	r := NewRenderer(opts)
+	_, _, err := r.Load(context.Background(), files)
+	is.NoError(err)
+
+	recs := r.activities[0].Records
+	is.Len(r.activities[0].Pauses, 1)
+	is.InDelta(1, recs[len(recs)-1].Percent, 1e-9)
+	is.Equal(recs[40].Percent, recs[41].Percent)
+	is.NoError(r.Encode(context.Background(), io.Discard))
+}
+
+func TestRendererCanceled(t *testing.T) {
+	is := require.New(t)
+
+	r := NewRenderer(testOptions())
+	is.ErrorIs(r.Encode(context.Background(), io.Discard), errNotLoaded)
+	_, _, err := r.Load(context.Background(), testFiles())
+	is.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	is.ErrorIs(r.Encode(ctx, io.Discard), context.Canceled)
+}
+
+func TestRendererProgress(t *testing.T) {
+	is := require.New(t)
+
+	mu := sync.Mutex{}
+	last := make(map[string][2]int64)
+	opts := testOptions()
+	opts.Jobs = 2
+	opts.Progress = progress.ReporterFunc(func(stage string, done, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		last[stage] = [2]int64{done, total}
+	})
+
+	r := NewRenderer(opts)
+	_, _, err := r.Load(context.Background(), testFiles())
+	is.NoError(err)
+	buf := &bytes.Buffer{}
+	is.NoError(r.Encode(context.Background(), buf))
+
+	is.Equal([2]int64{1, 1}, last[progress.Parse])
+	is.Equal([2]int64{4, 4}, last[progress.Render])
+	is.Equal([2]int64{int64(buf.Len()), int64(buf.Len())}, last[progress.Encode])
+}
@@ -0,0 +1,87 @@
+package worms
+
+import (
+	"sort"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+)
+
+// timeline maps wall-clock time onto a continuous animation offset, optionally compressing idle gaps between activities.
+type timeline struct {
+	spans []span
+}
+
+type span struct {
+	start, end time.Time
+	offset     time.Duration
+}
+
+func newTimeline(acts []*parse.Activity, maxGap time.Duration) *timeline {
+	spans := make([]span, 0, len(acts))
+	for _, act := range acts {
+		spans = append(spans, span{
+			start: act.Records[0].Timestamp,
+			end:   act.Records[len(act.Records)-1].Timestamp,
+		})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+
+	t := &timeline{}
+	for _, s := range spans {
+		if len(t.spans) == 0 {
+			t.spans = append(t.spans, s)
+			continue
+		}
+		last := &t.spans[len(t.spans)-1]
+		if !s.start.After(last.end) {
+			if s.end.After(last.end) {
+				last.end = s.end
+			}
+			continue
+		}
+		gap := s.start.Sub(last.end)
+		if maxGap > 0 && gap > maxGap {
+			gap = maxGap
+		}
+		s.offset = last.offset + last.end.Sub(last.start) + gap
+		t.spans = append(t.spans, s)
+	}
+	return t
+}
+
+func (t *timeline) duration() time.Duration {
+	if len(t.spans) == 0 {
+		return 0
+	}
+	last := t.spans[len(t.spans)-1]
+	return last.offset + last.end.Sub(last.start)
+}
+
+func (t *timeline) offset(ts time.Time) time.Duration {
+	i := sort.Search(len(t.spans), func(i int) bool { return t.spans[i].start.After(ts) }) - 1
+	if i < 0 {
+		return 0
+	}
+	s := t.spans[i]
+	if ts.After(s.end) {
+		ts = s.end
+	}
+	return s.offset + ts.Sub(s.start)
+}
+
+func (t *timeline) time(d time.Duration) time.Time {
+	i := sort.Search(len(t.spans), func(i int) bool { return t.spans[i].offset > d }) - 1
+	if i < 0 {
+		return t.spans[0].start
+	}
+	s := t.spans[i]
+	if ts := s.start.Add(d - s.offset); !ts.After(s.end) || i == len(t.spans)-1 {
+		return ts
+	}
+	// within a possibly compressed gap
+	next := t.spans[i+1]
+	gap := next.offset - s.offset - s.end.Sub(s.start)
+	frac := float64(d-s.offset-s.end.Sub(s.start)) / float64(gap)
+	return s.end.Add(time.Duration(frac * float64(next.start.Sub(s.end))))
+}
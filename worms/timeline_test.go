@@ -0,0 +1,37 @@
+package worms
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NathanBaulch/rainbow-roads/parse"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeline(t *testing.T) {
+	is := require.New(t)
+
+	ts := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	act := func(start, end time.Duration) *parse.Activity {
+		return &parse.Activity{Records: []*parse.Record{{Timestamp: ts.Add(start)}, {Timestamp: ts.Add(end)}}}
+	}
+	acts := []*parse.Activity{
+		act(48*time.Hour, 49*time.Hour),
+		act(0, time.Hour),
+		act(30*time.Minute, 90*time.Minute),
+	}
+
+	tl := newTimeline(acts, 0)
+	is.Equal(49*time.Hour, tl.duration())
+	is.Equal(time.Duration(0), tl.offset(ts))
+	is.Equal(80*time.Minute, tl.offset(ts.Add(80*time.Minute)))
+	is.Equal(48*time.Hour+30*time.Minute, tl.offset(ts.Add(48*time.Hour+30*time.Minute)))
+
+	tl = newTimeline(acts, time.Hour)
+	is.Equal(3*time.Hour+30*time.Minute, tl.duration())
+	is.Equal(80*time.Minute, tl.offset(ts.Add(80*time.Minute)))
+	is.Equal(150*time.Minute+30*time.Minute, tl.offset(ts.Add(48*time.Hour+30*time.Minute)))
+	is.Equal(ts.Add(80*time.Minute), tl.time(80*time.Minute))
+	is.Equal(ts.Add(90*time.Minute+23*time.Hour+15*time.Minute), tl.time(2*time.Hour))
+	is.Equal(ts.Add(48*time.Hour+30*time.Minute), tl.time(180*time.Minute))
+}